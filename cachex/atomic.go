@@ -0,0 +1,58 @@
+package cachex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// writeFileAtomic writes data to a temp file in the same directory, fsyncs it
+// and renames it over path, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (e error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create temp file fail %w", err)
+	}
+	tmp := f.Name()
+	defer func() {
+		if e != nil {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write temp file fail %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync temp file fail %w", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		return fmt.Errorf("chmod temp file fail %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close temp file fail %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename temp file fail %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes the rename durable. Windows can not open a directory for syncing.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open dir fail %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir fail %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,15 @@
 package cachex
 
+import "context"
+
 // ICache ...
 type ICache[T any] interface {
 	Set(*T) error
 	Get() (*T, bool, error)
 	Update(func(*T)) error
+	SetContext(context.Context, *T) error
+	UpdateContext(context.Context, func(*T)) error
+	Watch(context.Context) <-chan *T
 
 	MustSet(*T)
 	MustGet() (*T, bool)
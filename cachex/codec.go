@@ -0,0 +1,255 @@
+package cachex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec converts the cached value to bytes and back
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec is indented JSON, the default format of the file cache
+	JSONCodec Codec = jsonCodec{}
+	// YAMLCodec uses gopkg.in/yaml.v3
+	YAMLCodec Codec = yamlCodec{}
+	// TOMLCodec uses github.com/pelletier/go-toml/v2, the value must be a struct or a map
+	TOMLCodec Codec = tomlCodec{}
+	// GobCodec uses encoding/gob
+	GobCodec Codec = gobCodec{}
+	// MsgpackCodec uses github.com/vmihailenco/msgpack/v5, fields are named by their json tag
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// CodecFromExt picks a codec by file extension.
+// .yaml/.yml, .toml, .gob and .msgpack/.mp are recognized, anything else is JSON.
+// A trailing .gz or .zst compresses the inner format, e.g. state.yaml.zst
+func CodecFromExt(path string) Codec {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".gz":
+		return GzipCodec(CodecFromExt(strings.TrimSuffix(path, filepath.Ext(path))))
+	case ".zst", ".zstd":
+		return ZstdCodec(CodecFromExt(strings.TrimSuffix(path, filepath.Ext(path))))
+	case ".yaml", ".yml":
+		return YAMLCodec
+	case ".toml":
+		return TOMLCodec
+	case ".gob":
+		return GobCodec
+	case ".msgpack", ".mp":
+		return MsgpackCodec
+	default:
+		return JSONCodec
+	}
+}
+
+// GzipCodec compresses the output of c with gzip
+func GzipCodec(c Codec) Codec {
+	return gzipCodec{inner: c}
+}
+
+// ZstdCodec compresses the output of c with zstd
+func ZstdCodec(c Codec) Codec {
+	return zstdCodec{inner: c}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.MarshalIndent(v, "  ", "  ")
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// unmarshalDoc keeps numbers as json.Number, a float64 can not hold integers above 2^53
+func (jsonCodec) unmarshalDoc(data []byte, doc *map[string]any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(doc)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Marshal(v any) ([]byte, error) {
+	return toml.Marshal(v)
+}
+
+func (tomlCodec) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type gzipCodec struct {
+	inner Codec
+}
+
+func (c gzipCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("gzip fail %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("gzip fail %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Unmarshal(data []byte, v any) error {
+	raw, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.inner.Unmarshal(raw, v)
+}
+
+func (c gzipCodec) unmarshalDoc(data []byte, doc *map[string]any) error {
+	raw, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return unmarshalDoc(c.inner, raw, doc)
+}
+
+func (c gzipCodec) decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gunzip fail %w", err)
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("gunzip fail %w", err)
+	}
+	return raw, nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+
+	newZstdEncoder = func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) }
+	newZstdDecoder = func() (*zstd.Decoder, error) { return zstd.NewReader(nil) }
+)
+
+// the zstd encoder and decoder are safe for concurrent EncodeAll / DecodeAll
+func initZstd() error {
+	zstdOnce.Do(func() {
+		var err error
+		if zstdEncoder, err = newZstdEncoder(); err != nil {
+			zstdErr = fmt.Errorf("zstd new encoder fail %w", err)
+			return
+		}
+		if zstdDecoder, err = newZstdDecoder(); err != nil {
+			zstdErr = fmt.Errorf("zstd new decoder fail %w", err)
+		}
+	})
+	return zstdErr
+}
+
+type zstdCodec struct {
+	inner Codec
+}
+
+func (c zstdCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+func (c zstdCodec) Unmarshal(data []byte, v any) error {
+	raw, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.inner.Unmarshal(raw, v)
+}
+
+func (c zstdCodec) unmarshalDoc(data []byte, doc *map[string]any) error {
+	raw, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return unmarshalDoc(c.inner, raw, doc)
+}
+
+func (c zstdCodec) decompress(data []byte) ([]byte, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	raw, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd decode fail %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,89 @@
+package cachex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+type state struct {
+	Name  string            `json:"name" yaml:"name" toml:"name"`
+	Count int               `json:"count" yaml:"count" toml:"count"`
+	Tags  map[string]string `json:"tags" yaml:"tags" toml:"tags"`
+}
+
+func TestCodecFromExt(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"state.json", "state.yaml", "state.yml", "state.toml", "state.gob",
+		"state.msgpack", "state.json.gz", "state.yaml.zst", "state.cache",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			c, err := New[state](path, WithCodecFromExt())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &state{Name: "a", Count: 3, Tags: map[string]string{"k": "v"}}
+			assert.NoError(t, c.Set(want))
+			got, ok, err := c.Get()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, want, got)
+
+			// the file is written in the format of its extension
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			var decoded state
+			assert.NoError(t, CodecFromExt(path).Unmarshal(data, &decoded))
+			assert.Equal(t, want, &decoded)
+		})
+	}
+}
+
+type legacyState struct {
+	UserName string `json:"user_name"`
+	Count    int    `json:"count"`
+}
+
+func TestCodecLegacyJSON(t *testing.T) {
+	// written by the JSON-only version of the cache, whatever the extension
+	legacy := "{\n    \"user_name\": \"a\",\n    \"count\": 1\n  }"
+	for _, name := range []string{"state", "state.yaml", "state.toml", "state.gob"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := New[legacyState](path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok, err := c.Get()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, &legacyState{UserName: "a", Count: 1}, got)
+		})
+	}
+}
+
+func TestZstdInitError(t *testing.T) {
+	newEncoder := newZstdEncoder
+	t.Cleanup(func() {
+		newZstdEncoder = newEncoder
+		zstdOnce, zstdEncoder, zstdDecoder, zstdErr = sync.Once{}, nil, nil, nil
+	})
+	zstdOnce, zstdEncoder, zstdDecoder, zstdErr = sync.Once{}, nil, nil, nil
+	newZstdEncoder = func() (*zstd.Encoder, error) { return nil, errors.New("no memory") }
+
+	// the construction error is returned, nothing panics on a nil encoder
+	c := ZstdCodec(JSONCodec)
+	_, err := c.Marshal(state{Name: "a"})
+	assert.ErrorContains(t, err, "no memory")
+	assert.ErrorContains(t, c.Unmarshal([]byte("x"), &state{}), "no memory")
+}
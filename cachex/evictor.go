@@ -0,0 +1,116 @@
+package cachex
+
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
+
+type memoryEntry[T any] struct {
+	key      string
+	value    T
+	expireAt time.Time // zero means never expire
+	size     int64
+
+	// bookkeeping of the evictor
+	elem  *list.Element
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+func (e *memoryEntry[T]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// evictor tracks entry usage and picks the next one to evict
+type evictor[T any] interface {
+	add(e *memoryEntry[T])
+	touch(e *memoryEntry[T])
+	remove(e *memoryEntry[T])
+	victim() *memoryEntry[T]
+}
+
+func newLRUEvictor[T any]() *lruEvictor[T] {
+	return &lruEvictor[T]{l: list.New()}
+}
+
+// lruEvictor keeps the most recently used entry at the front
+type lruEvictor[T any] struct {
+	l *list.List
+}
+
+func (l *lruEvictor[T]) add(e *memoryEntry[T]) {
+	e.elem = l.l.PushFront(e)
+}
+
+func (l *lruEvictor[T]) touch(e *memoryEntry[T]) {
+	l.l.MoveToFront(e.elem)
+}
+
+func (l *lruEvictor[T]) remove(e *memoryEntry[T]) {
+	l.l.Remove(e.elem)
+}
+
+func (l *lruEvictor[T]) victim() *memoryEntry[T] {
+	return l.l.Back().Value.(*memoryEntry[T])
+}
+
+// lfuEvictor is a min-heap on (use count, last use)
+type lfuEvictor[T any] struct {
+	entries []*memoryEntry[T]
+	clock   uint64
+}
+
+func (l *lfuEvictor[T]) add(e *memoryEntry[T]) {
+	l.clock++
+	e.freq, e.tick = 1, l.clock
+	heap.Push(l, e)
+}
+
+func (l *lfuEvictor[T]) touch(e *memoryEntry[T]) {
+	l.clock++
+	e.freq++
+	e.tick = l.clock
+	heap.Fix(l, e.index)
+}
+
+func (l *lfuEvictor[T]) remove(e *memoryEntry[T]) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfuEvictor[T]) victim() *memoryEntry[T] {
+	return l.entries[0]
+}
+
+func (l *lfuEvictor[T]) Len() int {
+	return len(l.entries)
+}
+
+func (l *lfuEvictor[T]) Less(i, j int) bool {
+	a, b := l.entries[i], l.entries[j]
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (l *lfuEvictor[T]) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+func (l *lfuEvictor[T]) Push(x any) {
+	e := x.(*memoryEntry[T])
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+func (l *lfuEvictor[T]) Pop() any {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	return e
+}
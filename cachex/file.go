@@ -1,12 +1,14 @@
 package cachex
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/hilaily/kit/pathx"
@@ -18,11 +20,42 @@ var (
 )
 
 // New ...
-func New[T any](_filepath string) (*_cacheSrv[T], error) {
+func New[T any](_filepath string, ops ...Option) (*_cacheSrv[T], error) {
+	opt := defaultOption()
+	for _, o := range ops {
+		if err := o(opt); err != nil {
+			return nil, err
+		}
+	}
+	if opt.codec == nil {
+		opt.codec = JSONCodec
+		if opt.codecFromExt {
+			opt.codec = CodecFromExt(_filepath)
+		}
+	}
+	var sch *schema
+	if len(opt.migrations) > 0 {
+		s, err := newSchema(opt.migrations)
+		if err != nil {
+			return nil, err
+		}
+		sch = s
+	}
 	c := &_cacheSrv[T]{
-		file:     _filepath,
-		lockFile: _filepath + ".lock",
-		dir:      filepath.Dir(_filepath),
+		file:   _filepath,
+		dir:    filepath.Dir(_filepath),
+		perm:   opt.perm,
+		backup: opt.backup,
+		codec:  opt.codec,
+		schema: sch,
+
+		watchDebounce: opt.watchDebounce,
+		onWatchError:  opt.onWatchError,
+		lock: &fileLock{
+			path:     _filepath + ".lock",
+			timeout:  opt.lockTimeout,
+			interval: opt.lockInterval,
+		},
 	}
 	var t T
 	if reflect.TypeOf(t).Kind() == reflect.Ptr {
@@ -36,9 +69,19 @@ func New[T any](_filepath string) (*_cacheSrv[T], error) {
 }
 
 type _cacheSrv[T any] struct {
-	lockFile string
-	file     string
-	dir      string
+	file   string
+	dir    string
+	perm   os.FileMode
+	backup bool
+	codec  Codec
+	schema *schema
+	lock   *fileLock
+
+	watchDebounce time.Duration
+	onWatchError  func(error)
+
+	mu        sync.Mutex
+	lastWrite [sha256.Size]byte
 }
 
 func (rc *_cacheSrv[T]) MustSet(data *T) {
@@ -64,28 +107,53 @@ func (rc *_cacheSrv[T]) MustUpdate(f func(*T)) {
 }
 
 // Set ...
-func (rc *_cacheSrv[T]) Set(data *T) (e error) {
-	err := rc.tryLock()
+func (rc *_cacheSrv[T]) Set(data *T) error {
+	return rc.SetContext(context.Background(), data)
+}
+
+// SetContext is Set, ctx bounds the wait for the file lock
+func (rc *_cacheSrv[T]) SetContext(ctx context.Context, data *T) (e error) {
+	f, err := rc.lock.lock(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err := rc.unlock()
-		e = err
+		err := rc.lock.unlock(f)
+		if e == nil {
+			e = err
+		}
 	}()
 	return rc.set(data)
 }
 
 func (rc *_cacheSrv[T]) set(data *T) (e error) {
-	jobJSON, err := json.MarshalIndent(data, "  ", "  ")
+	var jobJSON []byte
+	var err error
+	if rc.schema != nil {
+		jobJSON, err = rc.schema.encode(rc.codec, data)
+	} else {
+		jobJSON, err = rc.codec.Marshal(data)
+	}
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(rc.file, jobJSON, 0777)
-	if err != nil {
+	if rc.backup {
+		// only a decodable generation is worth keeping, never back up a corrupt file
+		if old, err := os.ReadFile(rc.file); err == nil {
+			if _, err := rc.decode(old); err == nil {
+				if err := writeFileAtomic(rc.backupFile(), old, rc.perm); err != nil {
+					return fmt.Errorf("write backup fail %w", err)
+				}
+			}
+		}
+	}
+	if err := writeFileAtomic(rc.file, jobJSON, rc.perm); err != nil {
 		return err
 	}
+	rc.mu.Lock()
+	rc.lastWrite = sha256.Sum256(jobJSON)
+	rc.mu.Unlock()
 	return nil
 }
 
@@ -101,22 +169,65 @@ func (rc *_cacheSrv[T]) Get() (*T, bool, error) {
 		return &val, false, err
 	}
 
-	err = json.Unmarshal([]byte(jobJSON), &val)
+	v, err := rc.decode(jobJSON)
+	if err == nil {
+		return v, true, nil
+	}
+	if rc.backup {
+		if bak, e := os.ReadFile(rc.backupFile()); e == nil {
+			if bv, e := rc.decode(bak); e == nil {
+				logrus.Warnf("%s is corrupt, use backup %s: %v", rc.file, rc.backupFile(), err)
+				return bv, true, nil
+			}
+		}
+	}
+	return v, true, err
+}
+
+func (rc *_cacheSrv[T]) decode(data []byte) (*T, error) {
+	var val T
+	if rc.schema != nil {
+		_, err := rc.schema.decode(rc.codec, data, &val, false)
+		return &val, err
+	}
+	err := rc.codec.Unmarshal(data, &val)
+	return &val, err
+}
+
+// MigrationDryRun migrates the current file in memory and reports what would change, nothing is written
+func (rc *_cacheSrv[T]) MigrationDryRun() (*MigrationReport, error) {
+	data, err := os.ReadFile(rc.file)
 	if err != nil {
-		return &val, true, err
+		return nil, err
 	}
-	return &val, true, nil
+	s := rc.schema
+	if s == nil {
+		s = &schema{}
+	}
+	var val T
+	return s.decode(rc.codec, data, &val, true)
+}
+
+func (rc *_cacheSrv[T]) backupFile() string {
+	return rc.file + ".bak"
 }
 
 // Update ...
-func (rc *_cacheSrv[T]) Update(f func(i *T)) (e error) {
-	err := rc.tryLock()
+func (rc *_cacheSrv[T]) Update(f func(i *T)) error {
+	return rc.UpdateContext(context.Background(), f)
+}
+
+// UpdateContext is Update, ctx bounds the wait for the file lock
+func (rc *_cacheSrv[T]) UpdateContext(ctx context.Context, f func(i *T)) (e error) {
+	lf, err := rc.lock.lock(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err := rc.unlock()
-		e = err
+		err := rc.lock.unlock(lf)
+		if e == nil {
+			e = err
+		}
 	}()
 	i, ok, err := rc.Get()
 	if err != nil {
@@ -129,29 +240,3 @@ func (rc *_cacheSrv[T]) Update(f func(i *T)) (e error) {
 	err = rc.set(i)
 	return err
 }
-
-func (rc *_cacheSrv[T]) tryLock() error {
-	count := 10
-	get := false
-	for i := 1; i <= count; i++ {
-		if pathx.IsExist(rc.lockFile) {
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
-		get = true
-		break
-	}
-	if get {
-		f, err := os.OpenFile(rc.lockFile, os.O_CREATE, 0777)
-		if err != nil {
-			return fmt.Errorf("write lock file fail %w", err)
-		}
-		defer f.Close()
-		return nil
-	}
-	return fmt.Errorf("can not get lock, timeout")
-}
-
-func (rc *_cacheSrv[T]) unlock() error {
-	return os.Remove(rc.lockFile)
-}
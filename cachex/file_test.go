@@ -0,0 +1,110 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counter struct {
+	N int `json:"n"`
+}
+
+func TestFileCacheConcurrentUpdate(t *testing.T) {
+	c, err := New[counter](filepath.Join(t.TempDir(), "counter.json"), WithLockTimeout(10*time.Second), WithLockRetryInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.Update(func(v *counter) { v.N++ }))
+		}()
+	}
+	wg.Wait()
+
+	v, ok, err := c.Get()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 20, v.N)
+}
+
+func TestFileCacheLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.json")
+	c, err := New[counter](path, WithLockTimeout(200*time.Millisecond), WithLockRetryInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err := c.lock.lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path + ".lock")
+	assert.Equal(t, strconv.Itoa(os.Getpid()), string(data))
+
+	err = c.Set(&counter{N: 1})
+	assert.True(t, errors.Is(err, ErrLockTimeout), err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.SetContext(ctx, &counter{N: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.NoError(t, c.lock.unlock(holder))
+	assert.NoError(t, c.Set(&counter{N: 1}))
+}
+
+func TestFileCacheStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.json")
+	// a lock file left behind by a crashed process
+	if err := os.WriteFile(path+".lock", []byte("999999999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New[counter](path, WithLockTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Set(&counter{N: 2}))
+	v, _, err := c.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v.N)
+}
+
+func TestFileCacheBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.json")
+	c, err := New[counter](path, WithBackup(), WithFileMode(0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Set(&counter{N: 1}))
+	assert.NoError(t, c.Set(&counter{N: 2}))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// simulate a crash that left a truncated primary file
+	assert.NoError(t, os.WriteFile(path, []byte(`{"n":`), 0600))
+	v, ok, err := c.Get()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v.N)
+
+	// the corrupt primary must not overwrite the good backup
+	assert.NoError(t, c.Update(func(v *counter) { v.N = 3 }))
+	v, _, err = c.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v.N)
+	bak, err := os.ReadFile(path + ".bak")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"n":1}`, string(bak))
+}
@@ -1,6 +1,14 @@
 package cachex
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NoExpiration as a ttl keeps the key until it is deleted
+const NoExpiration time.Duration = -1
 
 type IKVCache[T any] interface {
 	Set(key string, value T) error
@@ -8,3 +16,63 @@ type IKVCache[T any] interface {
 	Get(key string) (T, bool, error)
 	Del(k string)
 }
+
+// IKVCacheV2 is the context-aware IKVCache, every operation reports its error.
+// A zero ttl uses the default timeout of the cache, NoExpiration never expires.
+type IKVCacheV2[T any] interface {
+	Get(ctx context.Context, key string) (T, bool, error)
+	Set(ctx context.Context, key string, value T, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+
+	// MGet returns the existing keys only
+	MGet(ctx context.Context, keys ...string) (map[string]T, error)
+	MSet(ctx context.Context, values map[string]T, ttl time.Duration) error
+	DelPrefix(ctx context.Context, prefix string) error
+	// TTL returns the remaining time to live, NoExpiration if the key never expires
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+	// Keys calls fn for every key with the prefix until fn returns false
+	Keys(ctx context.Context, prefix string, fn func(key string) bool) error
+}
+
+var (
+	_ IKVCache[any] = &kvCacheV1[any]{}
+)
+
+// NewKVCacheV1 adapts an IKVCacheV2 to the old IKVCache interface
+func NewKVCacheV1[T any](c IKVCacheV2[T]) *kvCacheV1[T] {
+	return &kvCacheV1[T]{c: c}
+}
+
+type kvCacheV1[T any] struct {
+	c IKVCacheV2[T]
+}
+
+// V2 returns the underlying IKVCacheV2
+func (a *kvCacheV1[T]) V2() IKVCacheV2[T] {
+	return a.c
+}
+
+func (a *kvCacheV1[T]) Set(key string, value T) error {
+	return a.c.Set(context.Background(), key, value, 0)
+}
+
+func (a *kvCacheV1[T]) Get(key string) (T, bool, error) {
+	return a.c.Get(context.Background(), key)
+}
+
+func (a *kvCacheV1[T]) SetWithTime(key string, value T, t time.Time) {
+	ttl := time.Until(t)
+	if ttl <= 0 {
+		a.Del(key)
+		return
+	}
+	if err := a.c.Set(context.Background(), key, value, ttl); err != nil {
+		logrus.Errorf("kv cache set %s fail: %v", key, err)
+	}
+}
+
+func (a *kvCacheV1[T]) Del(key string) {
+	if err := a.c.Del(context.Background(), key); err != nil {
+		logrus.Errorf("kv cache del %s fail: %v", key, err)
+	}
+}
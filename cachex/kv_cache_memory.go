@@ -1,39 +1,333 @@
 package cachex
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/hilaily/kit/mapx"
 )
 
 var (
-	_ IKVCache[any] = &memoryKVCache[any]{}
+	_ IKVCache[any]   = &memoryKVCache[any]{}
+	_ IKVCacheV2[any] = &memoryKVCacheV2[any]{}
+)
+
+// expired entries are swept on write at most once per interval, reads drop them lazily
+const memorySweepInterval = time.Minute
+
+// EvictionPolicy decides which entry leaves a full memory cache
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used entry
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used entry, ties go to the least recently used
+	EvictLFU
 )
 
-func NewKVCacheFromMemory[T any](timeout time.Duration) *memoryKVCache[T] {
+// MemoryOption configures the memory KV cache
+type MemoryOption func(*memoryOption)
+
+type memoryOption struct {
+	maxEntries int
+	maxBytes   int64
+	sizer      func(key string, value any) int64
+	policy     EvictionPolicy
+}
+
+// WithMaxEntries bounds the number of entries, zero means unbounded
+func WithMaxEntries(n int) MemoryOption {
+	return func(o *memoryOption) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the total size of the entries as measured by the sizer, zero means unbounded
+func WithMaxBytes(n int64) MemoryOption {
+	return func(o *memoryOption) {
+		o.maxBytes = n
+	}
+}
+
+// WithSizer measures an entry for WithMaxBytes, by default it is the length of the key plus the JSON encoded value
+func WithSizer(sizer func(key string, value any) int64) MemoryOption {
+	return func(o *memoryOption) {
+		o.sizer = sizer
+	}
+}
+
+// WithEviction sets the policy used when the cache is full, default is EvictLRU
+func WithEviction(policy EvictionPolicy) MemoryOption {
+	return func(o *memoryOption) {
+		o.policy = policy
+	}
+}
+
+func jsonSizer(key string, value any) int64 {
+	data, _ := json.Marshal(value)
+	return int64(len(key) + len(data))
+}
+
+// Stats are the counters of a memory KV cache
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Bytes       int64
+}
+
+// NewKVCacheFromMemory keeps values in process memory for timeout. As before the V2 cache,
+// a timeout <= 0 makes Set expire the value at once, use NewKVCacheV2FromMemory to never expire.
+func NewKVCacheFromMemory[T any](timeout time.Duration, ops ...MemoryOption) *memoryKVCache[T] {
+	store := NewKVCacheV2FromMemory[T](timeout, ops...)
 	return &memoryKVCache[T]{
-		data: mapx.NewCacheMap2[T](timeout, true),
+		kvCacheV1: NewKVCacheV1[T](store),
+		store:     store,
+		timeout:   timeout,
 	}
 }
 
+// memoryKVCache is the IKVCache view of memoryKVCacheV2
 type memoryKVCache[T any] struct {
-	data *mapx.CacheMap2[T]
+	*kvCacheV1[T]
+	store   *memoryKVCacheV2[T]
+	timeout time.Duration
 }
 
 func (c *memoryKVCache[T]) Set(key string, value T) error {
-	c.data.Set(key, value)
+	if c.timeout <= 0 {
+		// the value is already expired, only the old one has to go
+		return c.store.Del(context.Background(), key)
+	}
+	return c.kvCacheV1.Set(key, value)
+}
+
+// Stats returns a snapshot of the counters
+func (c *memoryKVCache[T]) Stats() Stats {
+	return c.store.Stats()
+}
+
+// NewKVCacheV2FromMemory is the IKVCacheV2 version of NewKVCacheFromMemory, a zero timeout never expires
+func NewKVCacheV2FromMemory[T any](timeout time.Duration, ops ...MemoryOption) *memoryKVCacheV2[T] {
+	opt := &memoryOption{}
+	for _, o := range ops {
+		o(opt)
+	}
+	if opt.maxBytes > 0 && opt.sizer == nil {
+		opt.sizer = jsonSizer
+	}
+	c := &memoryKVCacheV2[T]{
+		timeout:   timeout,
+		opt:       opt,
+		data:      make(map[string]*memoryEntry[T]),
+		nextSweep: time.Now().Add(memorySweepInterval),
+	}
+	if opt.policy == EvictLFU {
+		c.evictor = &lfuEvictor[T]{}
+	} else {
+		c.evictor = newLRUEvictor[T]()
+	}
+	return c
+}
+
+type memoryKVCacheV2[T any] struct {
+	mu        sync.Mutex
+	timeout   time.Duration
+	opt       *memoryOption
+	data      map[string]*memoryEntry[T]
+	evictor   evictor[T]
+	nextSweep time.Time
+	bytes     int64
+	stats     Stats
+}
+
+func (c *memoryKVCacheV2[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(time.Now(), key)
+}
+
+func (c *memoryKVCacheV2[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.set(now, key, value, ttl)
+	c.sweep(now)
+	c.evict(now, 0, 0)
+	return nil
+}
+
+func (c *memoryKVCacheV2[T]) Del(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		if e, ok := c.data[k]; ok {
+			c.remove(e)
+		}
+	}
+	return nil
+}
+
+func (c *memoryKVCacheV2[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	res := make(map[string]T, len(keys))
+	for _, k := range keys {
+		if v, ok, _ := c.get(now, k); ok {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+func (c *memoryKVCacheV2[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, v := range values {
+		c.set(now, k, v, ttl)
+	}
+	c.sweep(now)
+	c.evict(now, 0, 0)
+	return nil
+}
+
+func (c *memoryKVCacheV2[T]) DelPrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.data {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(e)
+		}
+	}
+	return nil
+}
+
+func (c *memoryKVCacheV2[T]) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	e, ok := c.data[key]
+	if !ok || e.expired(now) {
+		return 0, false, nil
+	}
+	if e.expireAt.IsZero() {
+		return NoExpiration, true, nil
+	}
+	return e.expireAt.Sub(now), true, nil
+}
+
+// Keys iterates over a snapshot, fn may call back into the cache
+func (c *memoryKVCacheV2[T]) Keys(ctx context.Context, prefix string, fn func(key string) bool) error {
+	c.mu.Lock()
+	now := time.Now()
+	keys := make([]string, 0, len(c.data))
+	for k, e := range c.data {
+		if strings.HasPrefix(k, prefix) && !e.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(k) {
+			return nil
+		}
+	}
 	return nil
 }
 
-func (c *memoryKVCache[T]) Get(key string) (T, bool, error) {
-	v, ok := c.data.Get(key)
-	return v, ok, nil
+// Stats returns a snapshot of the counters
+func (c *memoryKVCacheV2[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.data)
+	s.Bytes = c.bytes
+	return s
+}
+
+func (c *memoryKVCacheV2[T]) get(now time.Time, key string) (T, bool, error) {
+	e, ok := c.data[key]
+	if ok && e.expired(now) {
+		c.remove(e)
+		c.stats.Expirations++
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var v T
+		return v, false, nil
+	}
+	c.stats.Hits++
+	c.evictor.touch(e)
+	return e.value, true, nil
+}
+
+func (c *memoryKVCacheV2[T]) set(now time.Time, key string, value T, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = c.timeout
+	}
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = now.Add(ttl)
+	}
+	var size int64
+	if c.opt.sizer != nil {
+		size = c.opt.sizer(key, value)
+	}
+
+	if e, ok := c.data[key]; ok {
+		c.bytes += size - e.size
+		e.value, e.expireAt, e.size = value, expireAt, size
+		c.evictor.touch(e)
+		return
+	}
+	// make room before adding, otherwise LFU would always evict the newcomer
+	c.evict(now, 1, size)
+	e := &memoryEntry[T]{key: key, value: value, expireAt: expireAt, size: size}
+	c.data[key] = e
+	c.bytes += size
+	c.evictor.add(e)
 }
 
-func (c *memoryKVCache[T]) SetWithTime(key string, value T, t time.Time) {
-	c.data.SetWithTime(key, value, t)
+func (c *memoryKVCacheV2[T]) remove(e *memoryEntry[T]) {
+	delete(c.data, e.key)
+	c.bytes -= e.size
+	c.evictor.remove(e)
 }
 
-func (c *memoryKVCache[T]) Del(key string) {
-	c.data.Del(key)
+func (c *memoryKVCacheV2[T]) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	c.nextSweep = now.Add(memorySweepInterval)
+	for _, e := range c.data {
+		if e.expired(now) {
+			c.remove(e)
+			c.stats.Expirations++
+		}
+	}
+}
+
+// evict drops entries chosen by the policy until n more entries of size bytes fit the bounds
+func (c *memoryKVCacheV2[T]) evict(now time.Time, n int, size int64) {
+	for len(c.data) > 0 &&
+		((c.opt.maxEntries > 0 && len(c.data)+n > c.opt.maxEntries) ||
+			(c.opt.maxBytes > 0 && c.bytes+size > c.opt.maxBytes)) {
+		e := c.evictor.victim()
+		c.remove(e)
+		if e.expired(now) {
+			c.stats.Expirations++
+		} else {
+			c.stats.Evictions++
+		}
+	}
 }
@@ -0,0 +1,196 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ IKVCacheV2[any] = &redisKVCacheV2[any]{}
+)
+
+// keys are scanned and deleted in batches of this size
+const redisScanCount = 500
+
+// IRedis is implemented by the client of github.com/hilaily/lib/redis
+type IRedis interface {
+	GetClient() *redis.Client
+}
+
+// NewKVCacheFromRedis stores values under prefix+key, encoded by codec (JSONCodec if nil).
+// Set expires keys after timeout, zero means never.
+func NewKVCacheFromRedis[T any](r IRedis, prefix string, codec Codec, timeout time.Duration) *kvCacheV1[T] {
+	return NewKVCacheV1[T](NewKVCacheV2FromRedis[T](r, prefix, codec, timeout))
+}
+
+// NewKVCacheV2FromRedis is the IKVCacheV2 version of NewKVCacheFromRedis
+func NewKVCacheV2FromRedis[T any](r IRedis, prefix string, codec Codec, timeout time.Duration) *redisKVCacheV2[T] {
+	if codec == nil {
+		codec = JSONCodec
+	}
+	return &redisKVCacheV2[T]{
+		client:  r.GetClient(),
+		prefix:  prefix,
+		codec:   codec,
+		timeout: timeout,
+	}
+}
+
+type redisKVCacheV2[T any] struct {
+	client  *redis.Client
+	prefix  string
+	codec   Codec
+	timeout time.Duration
+}
+
+func (c *redisKVCacheV2[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var v T
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return v, false, nil
+		}
+		return v, false, err
+	}
+	if err := c.codec.Unmarshal(data, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+func (c *redisKVCacheV2[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.prefix+key, data, c.expiration(ttl)).Err()
+}
+
+func (c *redisKVCacheV2[T]) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, c.keys(keys)...).Err()
+}
+
+func (c *redisKVCacheV2[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	res := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	vals, err := c.client.MGet(ctx, c.keys(keys)...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		var v T
+		if err := c.codec.Unmarshal([]byte(s), &v); err != nil {
+			return nil, err
+		}
+		res[keys[i]] = v
+	}
+	return res, nil
+}
+
+func (c *redisKVCacheV2[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	exp := c.expiration(ttl)
+	pipe := c.client.TxPipeline()
+	for k, v := range values {
+		data, err := c.codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, c.prefix+k, data, exp)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *redisKVCacheV2[T]) DelPrefix(ctx context.Context, prefix string) error {
+	batch := make([]string, 0, redisScanCount)
+	iter := c.client.Scan(ctx, 0, c.match(prefix), redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == redisScanCount {
+			if err := c.client.Unlink(ctx, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return c.client.Unlink(ctx, batch...).Err()
+	}
+	return nil
+}
+
+func (c *redisKVCacheV2[T]) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	d, err := c.client.PTTL(ctx, c.prefix+key).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	// go-redis passes through -2 when the key does not exist and -1 when it has no expiration
+	switch d {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return NoExpiration, true, nil
+	}
+	return d, true, nil
+}
+
+func (c *redisKVCacheV2[T]) Keys(ctx context.Context, prefix string, fn func(key string) bool) error {
+	iter := c.client.Scan(ctx, 0, c.match(prefix), redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		if !fn(strings.TrimPrefix(iter.Val(), c.prefix)) {
+			return nil
+		}
+	}
+	return iter.Err()
+}
+
+func (c *redisKVCacheV2[T]) expiration(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		ttl = c.timeout
+	}
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func (c *redisKVCacheV2[T]) keys(keys []string) []string {
+	res := make([]string, len(keys))
+	for i, k := range keys {
+		res[i] = c.prefix + k
+	}
+	return res
+}
+
+// match builds a SCAN pattern, glob characters in the prefix are escaped
+func (c *redisKVCacheV2[T]) match(prefix string) string {
+	var b strings.Builder
+	for _, r := range c.prefix + prefix {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('*')
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package cachex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRedis struct {
+	client *redis.Client
+}
+
+func (r *testRedis) GetClient() *redis.Client {
+	return r.client
+}
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, IRedis) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return s, &testRedis{client: client}
+}
+
+func TestRedisKVCache(t *testing.T) {
+	s, r := newTestRedis(t)
+	c := NewKVCacheFromRedis[state](r, "test:", MsgpackCodec, time.Minute)
+
+	_, ok, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	want := state{Name: "a", Count: 1}
+	assert.NoError(t, c.Set("a", want))
+	got, ok, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.True(t, s.Exists("test:a"))
+	assert.Equal(t, time.Minute, s.TTL("test:a"))
+
+	c.SetWithTime("b", want, time.Now().Add(time.Hour))
+	assert.InDelta(t, time.Hour.Seconds(), s.TTL("test:b").Seconds(), 2)
+
+	s.FastForward(2 * time.Hour)
+	_, ok, err = c.Get("b")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	c.Del("a")
+	_, ok, err = c.Get("a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
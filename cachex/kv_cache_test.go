@@ -0,0 +1,171 @@
+package cachex
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKVCacheV2(t *testing.T, c IKVCacheV2[state]) {
+	ctx := context.Background()
+	a, b := state{Name: "a"}, state{Name: "b"}
+
+	assert.NoError(t, c.Set(ctx, "user:1", a, 0))
+	assert.NoError(t, c.MSet(ctx, map[string]state{"user:2": b, "item:1": a}, time.Hour))
+
+	got, ok, err := c.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, a, got)
+
+	m, err := c.MGet(ctx, "user:1", "user:2", "user:3")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]state{"user:1": a, "user:2": b}, m)
+
+	ttl, ok, err := c.TTL(ctx, "user:2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 2)
+	assert.NoError(t, c.Set(ctx, "forever", a, NoExpiration))
+	ttl, ok, err = c.TTL(ctx, "forever")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, NoExpiration, ttl)
+	_, ok, err = c.TTL(ctx, "user:3")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var keys []string
+	assert.NoError(t, c.Keys(ctx, "user:", func(k string) bool {
+		keys = append(keys, k)
+		return true
+	}))
+	sort.Strings(keys)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	assert.NoError(t, c.DelPrefix(ctx, "user:"))
+	m, err = c.MGet(ctx, "user:1", "user:2", "item:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]state{"item:1": a}, m)
+
+	assert.NoError(t, c.Del(ctx, "item:1", "forever"))
+	_, ok, err = c.Get(ctx, "item:1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryKVCacheV2(t *testing.T) {
+	testKVCacheV2(t, NewKVCacheV2FromMemory[state](time.Minute))
+}
+
+func TestRedisKVCacheV2(t *testing.T) {
+	_, r := newTestRedis(t)
+	testKVCacheV2(t, NewKVCacheV2FromRedis[state](r, "test:", nil, time.Minute))
+}
+
+func TestKVCacheV1(t *testing.T) {
+	c := NewKVCacheFromMemory[string](time.Minute)
+	assert.NoError(t, c.Set("a", "1"))
+	c.SetWithTime("b", "2", time.Now().Add(50*time.Millisecond))
+	c.SetWithTime("c", "3", time.Now().Add(-time.Second))
+
+	v, ok, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	_, ok, _ = c.Get("c")
+	assert.False(t, ok)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok, _ = c.Get("b")
+	assert.False(t, ok)
+
+	c.Del("a")
+	_, ok, _ = c.V2().Get(context.Background(), "a")
+	assert.False(t, ok)
+}
+
+func TestKVCacheV1ZeroTimeout(t *testing.T) {
+	// the IKVCache constructor keeps its old meaning, a zero timeout expires at once
+	c := NewKVCacheFromMemory[string](0)
+	assert.NoError(t, c.Set("a", "1"))
+	_, ok, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// an explicit expire time still works
+	c.SetWithTime("b", "2", time.Now().Add(time.Minute))
+	v, ok, _ := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	assert.NoError(t, c.Set("b", "3"))
+	_, ok, _ = c.Get("b")
+	assert.False(t, ok)
+
+	// only the V2 constructor treats zero as never expire
+	v2 := NewKVCacheV2FromMemory[string](0)
+	assert.NoError(t, v2.Set(context.Background(), "a", "1", 0))
+	ttl, ok, _ := v2.TTL(context.Background(), "a")
+	assert.True(t, ok)
+	assert.Equal(t, NoExpiration, ttl)
+}
+
+func TestMemoryKVCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewKVCacheV2FromMemory[int](time.Minute, WithMaxEntries(2))
+	c.Set(ctx, "a", 1, 0)
+	c.Set(ctx, "b", 2, 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", 3, 0)
+
+	m, _ := c.MGet(ctx, "a", "b", "c")
+	assert.Equal(t, map[string]int{"a": 1, "c": 3}, m)
+	s := c.Stats()
+	assert.Equal(t, uint64(1), s.Evictions)
+	assert.Equal(t, uint64(3), s.Hits)
+	assert.Equal(t, uint64(1), s.Misses)
+	assert.Equal(t, 2, s.Entries)
+}
+
+func TestMemoryKVCacheLFU(t *testing.T) {
+	ctx := context.Background()
+	c := NewKVCacheV2FromMemory[int](time.Minute, WithMaxEntries(2), WithEviction(EvictLFU))
+	c.Set(ctx, "a", 1, 0)
+	c.Set(ctx, "b", 2, 0)
+	c.Get(ctx, "a")
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Set(ctx, "c", 3, 0) // b is used less than a
+	c.Set(ctx, "d", 4, 0) // c is used less than a
+
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "c")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "d")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), c.Stats().Evictions)
+}
+
+func TestMemoryKVCacheMaxBytes(t *testing.T) {
+	c := NewKVCacheFromMemory[string](time.Minute, WithMaxBytes(10), WithSizer(func(key string, value any) int64 {
+		return int64(len(value.(string)))
+	}))
+	c.Set("a", "1234")
+	c.Set("b", "1234")
+	c.Set("c", "1234")
+	s := c.Stats()
+	assert.Equal(t, 2, s.Entries)
+	assert.Equal(t, int64(8), s.Bytes)
+	assert.Equal(t, uint64(1), s.Evictions)
+
+	c.Set("b", "1")
+	assert.Equal(t, int64(5), c.Stats().Bytes)
+	c.Del("b")
+	assert.Equal(t, int64(4), c.Stats().Bytes)
+}
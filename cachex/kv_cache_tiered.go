@@ -0,0 +1,229 @@
+package cachex
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	_ IKVCacheV2[any] = &TieredKVCache[any]{}
+	_ IInvalidator    = &redisInvalidator{}
+)
+
+// Invalidation tells the other replicas to evict keys from their L1
+type Invalidation struct {
+	Node     string   `json:"node"`
+	Keys     []string `json:"keys,omitempty"`
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// IInvalidator broadcasts invalidations between replicas
+type IInvalidator interface {
+	Publish(ctx context.Context, msg *Invalidation) error
+	// Subscribe returns once the subscription is active,
+	// then calls fn for every message in the background until ctx is done.
+	Subscribe(ctx context.Context, fn func(msg *Invalidation)) error
+}
+
+// NewRedisInvalidator broadcasts invalidations over Redis pub/sub on channel
+func NewRedisInvalidator(r IRedis, channel string) *redisInvalidator {
+	return &redisInvalidator{
+		client:  r.GetClient(),
+		channel: channel,
+	}
+}
+
+type redisInvalidator struct {
+	client  *redis.Client
+	channel string
+}
+
+func (i *redisInvalidator) Publish(ctx context.Context, msg *Invalidation) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return i.client.Publish(ctx, i.channel, data).Err()
+}
+
+func (i *redisInvalidator) Subscribe(ctx context.Context, fn func(msg *Invalidation)) error {
+	sub := i.client.Subscribe(ctx, i.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return fmt.Errorf("subscribe %s fail %w", i.channel, err)
+	}
+	go func() {
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				var msg Invalidation
+				if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+					logrus.Warnf("invalid invalidation message on %s: %v", i.channel, err)
+					continue
+				}
+				fn(&msg)
+			}
+		}
+	}()
+	return nil
+}
+
+// NewTieredKVCache puts an in-memory L1 in front of the shared l2. Writes and deletes
+// go to l2 and are broadcast through inv, so other replicas evict their L1 copy.
+// l1TTL bounds how long a replica can serve a stale value when a broadcast is lost.
+func NewTieredKVCache[T any](l2 IKVCacheV2[T], inv IInvalidator, l1TTL time.Duration) (*TieredKVCache[T], error) {
+	node := make([]byte, 8)
+	if _, err := rand.Read(node); err != nil {
+		return nil, fmt.Errorf("generate node id fail %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &TieredKVCache[T]{
+		l1:     NewKVCacheV2FromMemory[T](l1TTL),
+		l2:     l2,
+		inv:    inv,
+		node:   hex.EncodeToString(node),
+		cancel: cancel,
+	}
+	if err := inv.Subscribe(ctx, c.onInvalidation); err != nil {
+		cancel()
+		return nil, err
+	}
+	return c, nil
+}
+
+// TieredKVCache is a two-tier IKVCacheV2, see NewTieredKVCache
+type TieredKVCache[T any] struct {
+	l1     *memoryKVCacheV2[T]
+	l2     IKVCacheV2[T]
+	inv    IInvalidator
+	node   string
+	cancel context.CancelFunc
+}
+
+// Close stops listening for invalidations
+func (c *TieredKVCache[T]) Close() {
+	c.cancel()
+}
+
+func (c *TieredKVCache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	if v, ok, _ := c.l1.Get(ctx, key); ok {
+		return v, true, nil
+	}
+	v, ok, err := c.l2.Get(ctx, key)
+	if err != nil || !ok {
+		return v, ok, err
+	}
+	c.l1.Set(ctx, key, v, 0)
+	return v, true, nil
+}
+
+func (c *TieredKVCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	c.l1.Set(ctx, key, value, c.l1TTL(ttl))
+	c.publish(ctx, &Invalidation{Keys: []string{key}})
+	return nil
+}
+
+func (c *TieredKVCache[T]) Del(ctx context.Context, keys ...string) error {
+	if err := c.l2.Del(ctx, keys...); err != nil {
+		return err
+	}
+	c.l1.Del(ctx, keys...)
+	c.publish(ctx, &Invalidation{Keys: keys})
+	return nil
+}
+
+func (c *TieredKVCache[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	res, _ := c.l1.MGet(ctx, keys...)
+	missing := make([]string, 0, len(keys)-len(res))
+	for _, k := range keys {
+		if _, ok := res[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return res, nil
+	}
+	remote, err := c.l2.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	c.l1.MSet(ctx, remote, 0)
+	for k, v := range remote {
+		res[k] = v
+	}
+	return res, nil
+}
+
+func (c *TieredKVCache[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if err := c.l2.MSet(ctx, values, ttl); err != nil {
+		return err
+	}
+	c.l1.MSet(ctx, values, c.l1TTL(ttl))
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	c.publish(ctx, &Invalidation{Keys: keys})
+	return nil
+}
+
+func (c *TieredKVCache[T]) DelPrefix(ctx context.Context, prefix string) error {
+	if err := c.l2.DelPrefix(ctx, prefix); err != nil {
+		return err
+	}
+	c.l1.DelPrefix(ctx, prefix)
+	c.publish(ctx, &Invalidation{Prefixes: []string{prefix}})
+	return nil
+}
+
+func (c *TieredKVCache[T]) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+func (c *TieredKVCache[T]) Keys(ctx context.Context, prefix string, fn func(key string) bool) error {
+	return c.l2.Keys(ctx, prefix, fn)
+}
+
+// l1TTL keeps a short-lived key from outliving its l2 copy in L1
+func (c *TieredKVCache[T]) l1TTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && (c.l1.timeout <= 0 || ttl < c.l1.timeout) {
+		return ttl
+	}
+	return 0
+}
+
+// publish failures are only logged, the L1 ttl still bounds how stale other replicas get
+func (c *TieredKVCache[T]) publish(ctx context.Context, msg *Invalidation) {
+	msg.Node = c.node
+	if err := c.inv.Publish(ctx, msg); err != nil {
+		logrus.Warnf("tiered kv cache publish invalidation fail: %v", err)
+	}
+}
+
+func (c *TieredKVCache[T]) onInvalidation(msg *Invalidation) {
+	if msg.Node == c.node {
+		return
+	}
+	ctx := context.Background()
+	c.l1.Del(ctx, msg.Keys...)
+	for _, p := range msg.Prefixes {
+		c.l1.DelPrefix(ctx, p)
+	}
+}
@@ -0,0 +1,61 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredKVCache(t *testing.T) {
+	_, r := newTestRedis(t)
+	ctx := context.Background()
+	newNode := func() *TieredKVCache[string] {
+		c, err := NewTieredKVCache(
+			NewKVCacheV2FromRedis[string](r, "tiered:", nil, time.Hour),
+			NewRedisInvalidator(r, "tiered:invalidate"),
+			time.Hour,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(c.Close)
+		return c
+	}
+	a, b := newNode(), newNode()
+
+	conformance, err := NewTieredKVCache(
+		NewKVCacheV2FromRedis[state](r, "conformance:", nil, time.Hour),
+		NewRedisInvalidator(r, "conformance:invalidate"),
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conformance.Close()
+	testKVCacheV2(t, conformance)
+
+	assert.NoError(t, a.Set(ctx, "k", "1", 0))
+	v, ok, err := b.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	_, ok, _ = b.l1.Get(ctx, "k")
+	assert.True(t, ok)
+
+	// a write on a evicts the L1 copy of b
+	assert.NoError(t, a.Set(ctx, "k", "2", 0))
+	assert.Eventually(t, func() bool {
+		_, ok, _ := b.l1.Get(ctx, "k")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+	v, _, _ = b.Get(ctx, "k")
+	assert.Equal(t, "2", v)
+
+	assert.NoError(t, a.DelPrefix(ctx, "k"))
+	assert.Eventually(t, func() bool {
+		_, ok, _ := b.Get(ctx, "k")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,165 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned by a LoaderFunc when the key does not exist at the source,
+// with WithNegativeTTL the miss is cached and GetOrLoad keeps returning it.
+var ErrNotFound = errors.New("not found")
+
+// LoaderFunc loads the value of key from the source of truth
+type LoaderFunc[T any] func(ctx context.Context, key string) (T, error)
+
+// LoadEntry is the value LoadingCache keeps in its backend
+type LoadEntry[T any] struct {
+	Value     T         `json:"value"`
+	NotFound  bool      `json:"not_found,omitempty"`
+	RefreshAt time.Time `json:"refresh_at,omitempty"`
+}
+
+// LoadingOption configures a LoadingCache
+type LoadingOption func(*loadingOption)
+
+type loadingOption struct {
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	refreshAfter time.Duration
+	loadTimeout  time.Duration
+}
+
+// WithLoadTTL sets how long a loaded value stays in the backend, zero uses the backend default
+func WithLoadTTL(ttl time.Duration) LoadingOption {
+	return func(o *loadingOption) {
+		o.ttl = ttl
+	}
+}
+
+// WithNegativeTTL caches ErrNotFound results for ttl, zero disables negative caching
+func WithNegativeTTL(ttl time.Duration) LoadingOption {
+	return func(o *loadingOption) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithRefreshAfter makes a value stale after d, a stale value is still returned
+// while a single background load refreshes it. It should be shorter than the load ttl.
+func WithRefreshAfter(d time.Duration) LoadingOption {
+	return func(o *loadingOption) {
+		o.refreshAfter = d
+	}
+}
+
+// WithLoadTimeout bounds every load, loads are detached from the cancellation of the caller
+// because one load serves all concurrent callers
+func WithLoadTimeout(d time.Duration) LoadingOption {
+	return func(o *loadingOption) {
+		o.loadTimeout = d
+	}
+}
+
+// NewLoadingCache builds a read-through cache on top of c
+func NewLoadingCache[T any](c IKVCacheV2[LoadEntry[T]], ops ...LoadingOption) *LoadingCache[T] {
+	opt := &loadingOption{
+		loadTimeout: 30 * time.Second,
+	}
+	for _, o := range ops {
+		o(opt)
+	}
+	return &LoadingCache[T]{
+		c:   c,
+		opt: opt,
+	}
+}
+
+// LoadingCache collapses concurrent misses of a key into one load
+type LoadingCache[T any] struct {
+	c       IKVCacheV2[LoadEntry[T]]
+	opt     *loadingOption
+	group   singleflight.Group
+	refresh singleflight.Group
+}
+
+// GetOrLoad returns the cached value of key, or loads it with loader on a miss
+func (l *LoadingCache[T]) GetOrLoad(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
+	var zero T
+	e, ok, err := l.c.Get(ctx, key)
+	if err != nil {
+		logrus.Warnf("loading cache get %s fail, load from source: %v", key, err)
+	}
+	if err == nil && ok {
+		if e.NotFound {
+			return zero, ErrNotFound
+		}
+		if !e.RefreshAt.IsZero() && time.Now().After(e.RefreshAt) {
+			l.refreshInBackground(ctx, key, loader)
+		}
+		return e.Value, nil
+	}
+
+	ch := l.group.DoChan(key, func() (any, error) {
+		return l.load(ctx, key, loader)
+	})
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		// a nil value of an interface T does not survive the trip through any
+		v, _ := res.Val.(T)
+		return v, nil
+	}
+}
+
+// Invalidate drops the cached value of key, the next GetOrLoad loads it again
+func (l *LoadingCache[T]) Invalidate(ctx context.Context, key string) error {
+	return l.c.Del(ctx, key)
+}
+
+func (l *LoadingCache[T]) refreshInBackground(ctx context.Context, key string, loader LoaderFunc[T]) {
+	l.refresh.DoChan(key, func() (any, error) {
+		v, err := l.load(ctx, key, loader)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logrus.Warnf("loading cache refresh %s fail, keep the stale value: %v", key, err)
+		}
+		return v, err
+	})
+}
+
+func (l *LoadingCache[T]) load(ctx context.Context, key string, loader LoaderFunc[T]) (T, error) {
+	ctx = context.WithoutCancel(ctx)
+	if l.opt.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.opt.loadTimeout)
+		defer cancel()
+	}
+
+	v, err := loader(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		if l.opt.negativeTTL > 0 {
+			if e := l.c.Set(ctx, key, LoadEntry[T]{NotFound: true}, l.opt.negativeTTL); e != nil {
+				logrus.Warnf("loading cache set %s fail: %v", key, e)
+			}
+		}
+		return v, err
+	}
+	if err != nil {
+		return v, err
+	}
+
+	e := LoadEntry[T]{Value: v}
+	if l.opt.refreshAfter > 0 {
+		e.RefreshAt = time.Now().Add(l.opt.refreshAfter)
+	}
+	if err := l.c.Set(ctx, key, e, l.opt.ttl); err != nil {
+		logrus.Warnf("loading cache set %s fail: %v", key, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,94 @@
+package cachex
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadingCacheSingleflight(t *testing.T) {
+	l := NewLoadingCache(NewKVCacheV2FromMemory[LoadEntry[string]](time.Minute))
+	var calls int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return "v:" + key, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.GetOrLoad(context.Background(), "a", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "v:a", v)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	v, err := l.GetOrLoad(context.Background(), "a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "v:a", v)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestLoadingCacheNegative(t *testing.T) {
+	l := NewLoadingCache(NewKVCacheV2FromMemory[LoadEntry[string]](time.Minute), WithNegativeTTL(time.Minute))
+	var calls int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		_, err := l.GetOrLoad(context.Background(), "missing", loader)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestLoadingCacheNilInterface(t *testing.T) {
+	l := NewLoadingCache(NewKVCacheV2FromMemory[LoadEntry[any]](time.Minute))
+	loader := func(ctx context.Context, key string) (any, error) {
+		return nil, nil
+	}
+	v, err := l.GetOrLoad(context.Background(), "nil", loader)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	le := NewLoadingCache(NewKVCacheV2FromMemory[LoadEntry[error]](time.Minute))
+	e, err := le.GetOrLoad(context.Background(), "nil", func(ctx context.Context, key string) (error, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestLoadingCacheStaleWhileRevalidate(t *testing.T) {
+	l := NewLoadingCache(NewKVCacheV2FromMemory[LoadEntry[int32]](time.Minute), WithRefreshAfter(20*time.Millisecond))
+	var calls int32
+	loader := func(ctx context.Context, key string) (int32, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+	ctx := context.Background()
+
+	v, err := l.GetOrLoad(ctx, "a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), v)
+
+	time.Sleep(30 * time.Millisecond)
+	// the stale value is served while the refresh runs
+	v, err = l.GetOrLoad(ctx, "a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), v)
+
+	assert.Eventually(t, func() bool {
+		v, err := l.GetOrLoad(ctx, "a", loader)
+		return err == nil && v == 2
+	}, time.Second, 5*time.Millisecond)
+}
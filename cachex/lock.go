@@ -0,0 +1,110 @@
+package cachex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrLockTimeout is returned when the file lock can not be acquired in time
+	ErrLockTimeout = errors.New("can not get lock, timeout")
+
+	errLockBusy = errors.New("lock is held by another process")
+)
+
+// fileLock is an advisory OS-level lock (flock / LockFileEx) on a sidecar file.
+// The holder writes its pid into the file, so a waiter can tell who owns the lock.
+// The OS drops the lock when the holder dies, the pid left in the file by a dead
+// process is detected and cleared by the next holder.
+type fileLock struct {
+	path     string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// lock blocks until the lock is acquired, the timeout expires or ctx is done.
+func (l *fileLock) lock(ctx context.Context) (*os.File, error) {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file fail %w", err)
+	}
+
+	var deadline <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		err = lockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockBusy) {
+			f.Close()
+			return nil, fmt.Errorf("lock file fail %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-deadline:
+			pid := readLockPID(f)
+			f.Close()
+			return nil, fmt.Errorf("%w, held by pid %d", ErrLockTimeout, pid)
+		case <-time.After(l.interval):
+		}
+	}
+
+	if pid := readLockPID(f); pid > 0 && pid != os.Getpid() && !processAlive(pid) {
+		logrus.Warnf("clear stale lock %s left by dead process %d", l.path, pid)
+	}
+	if err := writeLockPID(f, os.Getpid()); err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, fmt.Errorf("write lock file fail %w", err)
+	}
+	return f, nil
+}
+
+// unlock clears the pid and releases the lock. The lock file itself is kept,
+// removing it would let two processes lock two different inodes of the same path.
+func (l *fileLock) unlock(f *os.File) error {
+	defer f.Close()
+	if err := f.Truncate(0); err != nil {
+		unlockFile(f)
+		return fmt.Errorf("clear lock file fail %w", err)
+	}
+	if err := unlockFile(f); err != nil {
+		return fmt.Errorf("unlock file fail %w", err)
+	}
+	return nil
+}
+
+func readLockPID(f *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func writeLockPID(f *os.File, pid int) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(pid)), 0)
+	return err
+}
@@ -0,0 +1,130 @@
+package cachex
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures the file cache created by New
+type Option = func(*option) error
+
+type option struct {
+	lockTimeout  time.Duration
+	lockInterval time.Duration
+	perm         os.FileMode
+	backup       bool
+	codec        Codec
+	codecFromExt bool
+	migrations   []Migration
+
+	watchDebounce time.Duration
+	onWatchError  func(error)
+}
+
+func defaultOption() *option {
+	return &option{
+		lockTimeout:  time.Second,
+		lockInterval: 100 * time.Millisecond,
+		perm:         0644,
+
+		watchDebounce: 100 * time.Millisecond,
+		onWatchError: func(err error) {
+			logrus.Errorf("cache watch error: %v", err)
+		},
+	}
+}
+
+// WithLockTimeout sets how long Set and Update wait for the file lock.
+// A zero timeout waits until the context passed to SetContext / UpdateContext is done.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(o *option) error {
+		if timeout < 0 {
+			return fmt.Errorf("lock timeout can not be negative: %s", timeout)
+		}
+		o.lockTimeout = timeout
+		return nil
+	}
+}
+
+// WithLockRetryInterval sets how often a busy lock is retried
+func WithLockRetryInterval(interval time.Duration) Option {
+	return func(o *option) error {
+		if interval <= 0 {
+			return fmt.Errorf("lock retry interval must be positive: %s", interval)
+		}
+		o.lockInterval = interval
+		return nil
+	}
+}
+
+// WithFileMode sets the permission of the cache file and its backup, default is 0644
+func WithFileMode(perm os.FileMode) Option {
+	return func(o *option) error {
+		o.perm = perm
+		return nil
+	}
+}
+
+// WithBackup keeps the previous generation in <file>.bak,
+// Get falls back to it when the primary file is corrupt.
+func WithBackup() Option {
+	return func(o *option) error {
+		o.backup = true
+		return nil
+	}
+}
+
+// WithCodec sets the file format, default is JSON
+func WithCodec(c Codec) Option {
+	return func(o *option) error {
+		if c == nil {
+			return fmt.Errorf("codec is nil")
+		}
+		o.codec = c
+		return nil
+	}
+}
+
+// WithCodecFromExt picks the file format from the file extension by CodecFromExt.
+// It is opt-in, files like state.yaml written by older versions are JSON.
+func WithCodecFromExt() Option {
+	return func(o *option) error {
+		o.codecFromExt = true
+		return nil
+	}
+}
+
+// WithWatchDebounce sets how long Watch waits for a burst of file events to settle
+func WithWatchDebounce(d time.Duration) Option {
+	return func(o *option) error {
+		if d <= 0 {
+			return fmt.Errorf("watch debounce must be positive: %s", d)
+		}
+		o.watchDebounce = d
+		return nil
+	}
+}
+
+// WithWatchErrorHandler receives the errors of Watch, by default they are logged
+func WithWatchErrorHandler(f func(error)) Option {
+	return func(o *option) error {
+		if f == nil {
+			return fmt.Errorf("watch error handler is nil")
+		}
+		o.onWatchError = f
+		return nil
+	}
+}
+
+// WithMigrations stores the schema version in the file and upgrades older files with ms,
+// in Version order, before they are decoded. The latest Version is the current schema.
+// The codec must be able to decode into a map[string]any, gob can not.
+func WithMigrations(ms ...Migration) Option {
+	return func(o *option) error {
+		o.migrations = append(o.migrations, ms...)
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package cachex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaVersionKey is the top-level field holding the schema version in a versioned cache file,
+// the value itself is stored under "data"
+const SchemaVersionKey = "_schema_version"
+
+// ErrSchemaTooNew is returned when the file was written by a newer schema than the registered migrations
+var ErrSchemaTooNew = errors.New("schema version is newer than the latest migration")
+
+// Migration upgrades a document from schema Version-1 to Version.
+// Up edits the decoded document in place, before it is decoded into T.
+type Migration struct {
+	Version int
+	Up      func(doc map[string]any) error
+}
+
+// MigrationReport is the result of a migration dry run
+type MigrationReport struct {
+	FromVersion int
+	ToVersion   int
+	Applied     []int
+	// UnknownFields are top-level fields left after migrating that T does not have
+	UnknownFields []string
+}
+
+// DryRunMigrations migrates data in memory and decodes it into T without writing anything,
+// it is meant for CI checks against fixture files of old schema versions.
+func DryRunMigrations[T any](data []byte, codec Codec, ms ...Migration) (*MigrationReport, error) {
+	s, err := newSchema(ms)
+	if err != nil {
+		return nil, err
+	}
+	var val T
+	return s.decode(codec, data, &val, true)
+}
+
+type schema struct {
+	migrations []Migration
+}
+
+func newSchema(ms []Migration) (*schema, error) {
+	sorted := append([]Migration(nil), ms...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration version must be positive: %d", m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up", m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+	return &schema{migrations: sorted}, nil
+}
+
+func (s *schema) current() int {
+	if len(s.migrations) == 0 {
+		return 0
+	}
+	return s.migrations[len(s.migrations)-1].Version
+}
+
+// schemaDataKey holds the value in a versioned file: {"_schema_version": N, "data": value}
+const schemaDataKey = "data"
+
+// envelope builds a *struct{Version int; Data <type of v>} so the value is decoded
+// and encoded as its own type, never through a map
+func envelope(v any) reflect.Value {
+	tag := func(name string) reflect.StructTag {
+		return reflect.StructTag(fmt.Sprintf(`json:"%s" yaml:"%s" toml:"%s"`, name, name, name))
+	}
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "Version", Type: reflect.TypeOf(0), Tag: tag(SchemaVersionKey)},
+		{Name: "Data", Type: reflect.TypeOf(v), Tag: tag(schemaDataKey)},
+	})
+	return reflect.New(t)
+}
+
+// decode upgrades data to the current version and decodes it into v, v must be a pointer.
+// A file of the current version is decoded straight into v, the document is only
+// decoded into a map when a migration has to run or a full report is asked for.
+func (s *schema) decode(codec Codec, data []byte, v any, full bool) (*MigrationReport, error) {
+	if !full {
+		env := envelope(v)
+		if err := codec.Unmarshal(data, env.Interface()); err == nil {
+			version := int(env.Elem().Field(0).Int())
+			val := env.Elem().Field(1)
+			if version == s.current() && !val.IsNil() {
+				reflect.ValueOf(v).Elem().Set(val.Elem())
+				return &MigrationReport{FromVersion: version, ToVersion: version}, nil
+			}
+		}
+	}
+
+	doc := map[string]any{}
+	if err := unmarshalDoc(codec, data, &doc); err != nil {
+		return nil, fmt.Errorf("schema versioning needs a codec that decodes into a map: %w", err)
+	}
+	from, err := versionOf(doc[SchemaVersionKey])
+	if err != nil {
+		return nil, err
+	}
+	report := &MigrationReport{FromVersion: from, ToVersion: s.current()}
+	if from > report.ToVersion {
+		return report, fmt.Errorf("%w: file %d, latest %d", ErrSchemaTooNew, from, report.ToVersion)
+	}
+
+	// files without a version are the bare value
+	payload := doc
+	if from > 0 {
+		inner, ok := doc[schemaDataKey].(map[string]any)
+		if !ok {
+			return report, fmt.Errorf("versioned file has no %q object", schemaDataKey)
+		}
+		payload = inner
+	} else {
+		delete(payload, SchemaVersionKey)
+	}
+	for _, m := range s.migrations {
+		if m.Version <= from {
+			continue
+		}
+		if err := m.Up(payload); err != nil {
+			return report, fmt.Errorf("migrate to version %d fail %w", m.Version, err)
+		}
+		report.Applied = append(report.Applied, m.Version)
+	}
+
+	migrated, err := codec.Marshal(payload)
+	if err != nil {
+		return report, err
+	}
+	if err := codec.Unmarshal(migrated, v); err != nil {
+		return report, err
+	}
+
+	// fields T does not know about are silently dropped by the codec, report them
+	known := knownFields(reflect.TypeOf(v).Elem())
+	if known != nil {
+		for k := range payload {
+			if _, ok := known[strings.ToLower(k)]; !ok {
+				report.UnknownFields = append(report.UnknownFields, k)
+			}
+		}
+	}
+	sort.Strings(report.UnknownFields)
+	return report, nil
+}
+
+// encode writes v, a pointer, with the current schema version
+func (s *schema) encode(codec Codec, v any) ([]byte, error) {
+	env := envelope(v)
+	env.Elem().Field(0).SetInt(int64(s.current()))
+	env.Elem().Field(1).Set(reflect.ValueOf(v))
+	return codec.Marshal(env.Interface())
+}
+
+// docDecoder is implemented by codecs whose generic decoding would lose integer precision
+type docDecoder interface {
+	unmarshalDoc(data []byte, doc *map[string]any) error
+}
+
+// unmarshalDoc decodes into a generic document, keeping integers exact where the codec allows
+func unmarshalDoc(codec Codec, data []byte, doc *map[string]any) error {
+	if d, ok := codec.(docDecoder); ok {
+		return d.unmarshalDoc(data, doc)
+	}
+	return codec.Unmarshal(data, doc)
+}
+
+// knownFields returns the lower-cased names a struct type can be decoded from,
+// by field name and json/yaml/toml tag. It is nil for types that are not structs.
+func knownFields(t reflect.Type) map[string]struct{} {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	known := map[string]struct{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for k := range knownFields(f.Type) {
+				known[k] = struct{}{}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		known[strings.ToLower(f.Name)] = struct{}{}
+		for _, key := range []string{"json", "yaml", "toml"} {
+			name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+			if name != "" && name != "-" {
+				known[strings.ToLower(name)] = struct{}{}
+			}
+		}
+	}
+	return known
+}
+
+func versionOf(v any) (int, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %v", SchemaVersionKey, v)
+		}
+		return int(i), nil
+	case int:
+		return n, nil
+	case int8:
+		return int(n), nil
+	case int16:
+		return int(n), nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case uint8:
+		return int(n), nil
+	case uint16:
+		return int(n), nil
+	case uint32:
+		return int(n), nil
+	case uint64:
+		return int(n), nil
+	case float32:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", SchemaVersionKey, v)
+	}
+}
@@ -0,0 +1,117 @@
+package cachex
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var stateMigrations = []Migration{
+	{Version: 2, Up: func(doc map[string]any) error {
+		doc["tags"] = map[string]any{"migrated": "yes"}
+		return nil
+	}},
+	{Version: 1, Up: func(doc map[string]any) error {
+		doc["count"] = doc["cnt"]
+		delete(doc, "cnt")
+		return nil
+	}},
+}
+
+func TestFileCacheMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"name":"a","cnt":3,"legacy":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New[state](path, WithMigrations(stateMigrations...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.MigrationDryRun()
+	assert.NoError(t, err)
+	assert.Equal(t, &MigrationReport{FromVersion: 0, ToVersion: 2, Applied: []int{1, 2}, UnknownFields: []string{"legacy"}}, report)
+
+	v, ok, err := c.Get()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &state{Name: "a", Count: 3, Tags: map[string]string{"migrated": "yes"}}, v)
+
+	assert.NoError(t, c.Update(func(v *state) { v.Count++ }))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var doc map[string]any
+	assert.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, float64(2), doc[SchemaVersionKey])
+	assert.Equal(t, float64(4), doc["data"].(map[string]any)["count"])
+
+	report, err = c.MigrationDryRun()
+	assert.NoError(t, err)
+	assert.Empty(t, report.Applied)
+
+	// a file from a newer release is refused instead of being decoded partially
+	old, err := New[state](path, WithMigrations(stateMigrations[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = old.Get()
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+}
+
+func TestDryRunMigrations(t *testing.T) {
+	report, err := DryRunMigrations[state]([]byte("name: a\ncnt: 1\n"), YAMLCodec, stateMigrations...)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, report.Applied)
+	assert.Empty(t, report.UnknownFields)
+
+	_, err = DryRunMigrations[state](nil, JSONCodec, Migration{Version: 1}, Migration{Version: 1})
+	assert.Error(t, err)
+}
+
+type event struct {
+	ID   int64  `json:"id"`
+	At   int64  `json:"at"`
+	Note string `json:"note,omitempty"`
+}
+
+func TestMigrationsKeepInt64(t *testing.T) {
+	// nanosecond timestamps do not fit in a float64
+	const at = int64(1760000000123456789)
+	for _, name := range []string{"event.json", "event.yaml", "event.toml", "event.json.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			codec := CodecFromExt(path)
+			legacy, err := codec.Marshal(map[string]any{"id": at, "ts": at})
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(path, legacy, 0644))
+
+			c, err := New[event](path, WithCodec(codec), WithMigrations(Migration{Version: 1, Up: func(doc map[string]any) error {
+				doc["at"] = doc["ts"]
+				delete(doc, "ts")
+				return nil
+			}}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, ok, err := c.Get()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, &event{ID: at, At: at}, v)
+
+			assert.NoError(t, c.Set(&event{ID: at + 1, At: at + 1}))
+			v, _, err = c.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, &event{ID: at + 1, At: at + 1}, v)
+		})
+	}
+}
+
+func TestDryRunMigrationsOmitempty(t *testing.T) {
+	// a zero omitempty field is still a field of T
+	report, err := DryRunMigrations[event]([]byte(`{"id":1,"note":"","extra":1}`), JSONCodec)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"extra"}, report.UnknownFields)
+}
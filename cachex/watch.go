@@ -0,0 +1,97 @@
+package cachex
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch emits the newly decoded value every time another process changes the file.
+// Bursts of events are debounced (WithWatchDebounce), writes done by this cache are skipped.
+// Decode and watcher errors go to the WithWatchErrorHandler handler.
+// The channel is closed when ctx is done or the watcher can not be started.
+func (rc *_cacheSrv[T]) Watch(ctx context.Context) <-chan *T {
+	ch := make(chan *T)
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		rc.onWatchError(fmt.Errorf("create watcher fail %w", err))
+		close(ch)
+		return ch
+	}
+	// watch the directory, an atomic write replaces the inode of the file
+	if err := w.Add(rc.dir); err != nil {
+		w.Close()
+		rc.onWatchError(fmt.Errorf("watch %s fail %w", rc.dir, err))
+		close(ch)
+		return ch
+	}
+
+	last, _ := os.ReadFile(rc.file)
+	go func() {
+		defer close(ch)
+		defer w.Close()
+
+		timer := time.NewTimer(0)
+		if !timer.Stop() {
+			<-timer.C
+		}
+		defer timer.Stop()
+		target := filepath.Clean(rc.file)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				rc.onWatchError(fmt.Errorf("watch %s fail %w", rc.file, err))
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) == target && (ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write)) {
+					timer.Reset(rc.watchDebounce)
+				}
+			case <-timer.C:
+				data, err := os.ReadFile(rc.file)
+				if err != nil {
+					if !errors.Is(err, os.ErrNotExist) {
+						rc.onWatchError(fmt.Errorf("read %s fail %w", rc.file, err))
+					}
+					continue
+				}
+				if bytes.Equal(data, last) || rc.isOwnWrite(data) {
+					last = data
+					continue
+				}
+				last = data
+				v, err := rc.decode(data)
+				if err != nil {
+					rc.onWatchError(fmt.Errorf("decode %s fail %w", rc.file, err))
+					continue
+				}
+				select {
+				case ch <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+func (rc *_cacheSrv[T]) isOwnWrite(data []byte) bool {
+	sum := sha256.Sum256(data)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.lastWrite == sum
+}
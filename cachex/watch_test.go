@@ -0,0 +1,61 @@
+package cachex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCacheWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.json")
+	errs := make(chan error, 10)
+	c1, err := New[counter](path, WithWatchDebounce(20*time.Millisecond), WithWatchErrorHandler(func(err error) {
+		errs <- err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another process sharing the same file
+	c2, err := New[counter](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := c1.Watch(ctx)
+
+	assert.NoError(t, c2.Set(&counter{N: 1}))
+	select {
+	case v := <-ch:
+		assert.Equal(t, 1, v.N)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change received")
+	}
+
+	// own writes are not echoed back
+	assert.NoError(t, c1.Set(&counter{N: 2}))
+	select {
+	case v := <-ch:
+		t.Fatalf("unexpected change %+v", v)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"n":`), 0644))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("parse error not reported")
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,187 @@
+package dlock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// conformanceBackend creates locks on the same key with different values
+type conformanceBackend struct {
+	newLock func(value string) IDLock
+	// expire makes the current lease run out, nil for locks without expiration
+	expire func()
+}
+
+func testConformance(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	l1 := b.newLock("client-1")
+	l2 := b.newLock("client-2")
+
+	t.Run("exclusive", func(t *testing.T) {
+		assert.NoError(t, l1.TryLock(ctx))
+		assert.ErrorIs(t, l2.TryLock(ctx), ErrLockAcquireFailed)
+		assert.NoError(t, l1.Refresh(ctx))
+		assert.ErrorIs(t, l2.Refresh(ctx), ErrLockReleaseFailed)
+		assert.ErrorIs(t, l2.Unlock(ctx), ErrLockReleaseFailed)
+		assert.NoError(t, l1.Unlock(ctx))
+		assert.ErrorIs(t, l1.Unlock(ctx), ErrLockReleaseFailed)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		assert.NoError(t, l1.TryLock(ctx))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			l1.Unlock(ctx)
+		}()
+		assert.NoError(t, l2.WaitLock(ctx, 2*time.Second))
+		assert.ErrorIs(t, l1.WaitLock(ctx, 100*time.Millisecond), ErrLockAcquireFailed)
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		if b.expire == nil {
+			t.Skip("lock does not expire")
+		}
+		assert.NoError(t, l1.TryLock(ctx))
+		b.expire()
+		assert.NoError(t, l2.TryLock(ctx))
+		assert.ErrorIs(t, l1.Refresh(ctx), ErrLockReleaseFailed)
+		assert.ErrorIs(t, l1.Unlock(ctx), ErrLockReleaseFailed)
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+}
+
+func TestConformanceRedis(t *testing.T) {
+	s, client := newTestRedis(t)
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewRedisLock(client, "conformance", value, time.Second)
+		},
+		expire: func() { s.FastForward(2 * time.Second) },
+	})
+}
+
+func TestConformanceRedisFair(t *testing.T) {
+	s, client := newTestRedis(t)
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewRedisLock(client, "conformance", value, time.Second, WithFairWait())
+		},
+		expire: func() { s.FastForward(2 * time.Second) },
+	})
+}
+
+func TestConformanceRedLock(t *testing.T) {
+	servers, clients := newTestRedisNodes(t, 3)
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewRedLock(clients, "conformance", value, time.Second)
+		},
+		expire: func() {
+			for _, s := range servers {
+				s.FastForward(2 * time.Second)
+			}
+		},
+	})
+}
+
+type testMySQL struct {
+	db *gorm.DB
+}
+
+func (m testMySQL) GetWriteDB() *gorm.DB {
+	return m.db
+}
+
+func TestConformanceSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lock.db")), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	testConformanceSQL(t, db)
+}
+
+// TestConformanceMySQL runs against a real MySQL, e.g.
+// DLOCK_MYSQL_DSN='root:123456@tcp(127.0.0.1:3306)/test' go test -run Conformance ./...
+func TestConformanceMySQL(t *testing.T) {
+	dsn := os.Getenv("DLOCK_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DLOCK_MYSQL_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Migrator().DropTable(&mysqlLockRow{}))
+	testConformanceSQL(t, db)
+}
+
+func TestMySQLLockStatements(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	res := db.Model(&mysqlLockRow{}).Clauses(insertIgnore(db)).Create(map[string]any{
+		"lock_key":   "k",
+		"value":      "v",
+		"expires_at": gorm.Expr(dbNowMillis(db)+" + ?", 1000),
+	})
+	require.NoError(t, res.Error)
+	// a conflicting INSERT IGNORE affects no rows whatever the clientFoundRows setting is
+	assert.Contains(t, res.Statement.SQL.String(), "INSERT IGNORE INTO `dlocks`")
+	assert.NotContains(t, res.Statement.SQL.String(), "ON DUPLICATE KEY")
+	// expiration is computed from the server clock
+	assert.Contains(t, res.Statement.SQL.String(), "NOW(3)")
+}
+
+func testConformanceSQL(t *testing.T, db *gorm.DB) {
+	require.NoError(t, MigrateMySQLLock(testMySQL{db}))
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewMySQLLock(testMySQL{db}, "conformance", value, 300*time.Millisecond)
+		},
+		expire: func() { time.Sleep(350 * time.Millisecond) },
+	})
+}
+
+// TestConformanceMongo runs against a real MongoDB 4.2+, e.g.
+// DLOCK_MONGO_URI='mongodb://127.0.0.1:27017' go test -run Conformance ./...
+func TestConformanceMongo(t *testing.T) {
+	uri := os.Getenv("DLOCK_MONGO_URI")
+	if uri == "" {
+		t.Skip("DLOCK_MONGO_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, mongooptions.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Disconnect(ctx) })
+	coll := client.Database("dlock_test").Collection("locks")
+	require.NoError(t, coll.Drop(ctx))
+	require.NoError(t, EnsureMongoLockIndex(ctx, coll))
+
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewMongoLock(coll, "conformance", value, 300*time.Millisecond)
+		},
+		expire: func() { time.Sleep(350 * time.Millisecond) },
+	})
+}
+
+func TestConformanceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conformance.lock")
+	testConformance(t, conformanceBackend{
+		newLock: func(value string) IDLock {
+			return NewFileLock(path, value)
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ErrPanic 表示 Do 执行的函数发生了 panic，锁已经释放
+var ErrPanic = errors.New("panic while holding lock")
+
+// leased 是有租期的锁，Do 在函数执行期间会自动续期
+type leased interface {
+	leaseTime() time.Duration
+}
+
+func (rl *redisLock) leaseTime() time.Duration     { return rl.expiration }
+func (rl *redLock) leaseTime() time.Duration       { return rl.expiration }
+func (rl *reentrantLock) leaseTime() time.Duration { return rl.expiration }
+func (ml *mysqlLock) leaseTime() time.Duration     { return ml.expiration }
+func (ml *mongoLock) leaseTime() time.Duration     { return ml.expiration }
+
+// Do 获取锁后执行 fn，执行结束或 panic 时释放锁
+// 有租期的锁在 fn 执行期间每隔 1/3 过期时间续期一次，确认锁丢失时 fn 的 ctx 以 ErrLockLost 取消
+// 默认只尝试获取一次，可以通过 WithWaitTimeout 等待，WithWaitHook 和 WithHoldHook 可以上报等待和持有时间
+// 返回 fn 的错误、释放锁的错误和锁丢失的错误
+func Do(ctx context.Context, lock IDLock, fn func(ctx context.Context) error, ops ...Option) (err error) {
+	opt := newOptions(ops)
+
+	start := time.Now()
+	if opt.waitTimeout > 0 {
+		err = lock.WaitLock(ctx, opt.waitTimeout)
+	} else {
+		err = lock.TryLock(ctx)
+	}
+	if opt.onWait != nil {
+		opt.onWait(time.Since(start), err)
+	}
+	if err != nil {
+		return err
+	}
+
+	acquired := time.Now()
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	var hold holdState
+	if l, ok := lock.(leased); ok {
+		lockCtx = hold.start(lockCtx, l.leaseTime(), 1.0/3, lock.Refresh)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+		}
+		lost := context.Cause(lockCtx)
+		hold.stop()
+		cancel(nil)
+
+		// ctx 可能已经取消，释放锁不受影响
+		uctx, ucancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer ucancel()
+		uerr := lock.Unlock(uctx)
+		if opt.onHold != nil {
+			opt.onHold(time.Since(acquired))
+		}
+		if errors.Is(lost, ErrLockLost) {
+			err = errors.Join(err, ErrLockLost)
+		} else if uerr != nil {
+			err = errors.Join(err, uerr)
+		}
+	}()
+
+	return fn(lockCtx)
+}
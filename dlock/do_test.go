@@ -0,0 +1,74 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	lock := NewRedisLock(client, "do", "client-1", time.Second)
+	other := NewRedisLock(client, "do", "client-2", time.Second)
+
+	var waited, held bool
+	err := Do(ctx, lock, func(ctx context.Context) error {
+		assert.True(t, s.Exists("do"))
+		return nil
+	}, WithWaitHook(func(wait time.Duration, err error) {
+		waited = err == nil
+	}), WithHoldHook(func(hold time.Duration) {
+		held = true
+	}))
+	assert.NoError(t, err)
+	assert.True(t, waited)
+	assert.True(t, held)
+	assert.False(t, s.Exists("do"))
+
+	// fn errors and panics still release the lock
+	errFn := errors.New("fn failed")
+	assert.ErrorIs(t, Do(ctx, lock, func(ctx context.Context) error { return errFn }), errFn)
+	assert.False(t, s.Exists("do"))
+	assert.ErrorIs(t, Do(ctx, lock, func(ctx context.Context) error { panic("boom") }), ErrPanic)
+	assert.False(t, s.Exists("do"))
+
+	// busy lock, with and without waiting
+	assert.NoError(t, other.TryLock(ctx))
+	called := false
+	err = Do(ctx, lock, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrLockAcquireFailed)
+	assert.False(t, called)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		other.Unlock(ctx)
+	}()
+	assert.NoError(t, Do(ctx, lock, func(ctx context.Context) error { return nil }, WithWaitTimeout(time.Second)))
+}
+
+func TestDoLockLost(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	lock := NewRedisLock(client, "do", "client-1", 300*time.Millisecond)
+
+	err := Do(ctx, lock, func(ctx context.Context) error {
+		// the lease is renewed while fn runs
+		s.FastForward(250 * time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return s.TTL("do") > 250*time.Millisecond
+		}, time.Second, 10*time.Millisecond)
+
+		s.Set("do", "client-2")
+		<-ctx.Done()
+		assert.ErrorIs(t, context.Cause(ctx), ErrLockLost)
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, ErrLockLost)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,113 @@
+package dlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Elector 基于 Redis 锁的选主，多个副本竞争同一个租约 key，持有租约的副本是 leader
+// leader 通过看门狗续期租约，续期失败时放弃 leader 身份，其他副本在租约过期后接替
+type Elector struct {
+	lock        *redisLock
+	client      *redis.Client
+	key         string
+	retryPeriod time.Duration
+	leader      atomic.Bool
+
+	mu        sync.Mutex
+	onStarted func(ctx context.Context)
+	onStopped func()
+}
+
+// NewElector 创建一个选主器，identity 需要在所有副本之间唯一，lease 是租约时长
+// 租约每隔 lease/3 续期一次，非 leader 每隔 lease/3 左右尝试一次竞选
+func NewElector(client *redis.Client, key, identity string, lease time.Duration) *Elector {
+	return &Elector{
+		lock:        NewRedisLock(client, key, identity, lease, WithWatchdog(1.0/3)),
+		client:      client,
+		key:         key,
+		retryPeriod: lease / 3,
+	}
+}
+
+// OnStartedLeading 设置成为 leader 时的回调，在新的 goroutine 中执行
+// ctx 在失去 leader 身份时取消，回调应该在 ctx 取消后尽快退出
+// 回调返回之前不会调用 OnStoppedLeading，也不会再次竞选，同一个 Elector 的回调不会重叠
+func (e *Elector) OnStartedLeading(f func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onStarted = f
+}
+
+// OnStoppedLeading 设置失去 leader 身份时的回调，包括租约丢失和主动退出
+// 总是在这一任期的 OnStartedLeading 回调返回之后调用
+func (e *Elector) OnStoppedLeading(f func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onStopped = f
+}
+
+// IsLeader 返回当前副本是否是 leader
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Leader 返回当前 leader 的 identity，没有 leader 时返回 ErrLockNotHeld
+func (e *Elector) Leader(ctx context.Context) (string, error) {
+	info, err := Inspect(ctx, e.client, e.key)
+	if err != nil {
+		return "", err
+	}
+	return info.Holder, nil
+}
+
+// Run 持续竞选直到 ctx 取消，ctx 取消时如果是 leader 会释放租约，其他副本可以立即接替
+func (e *Elector) Run(ctx context.Context) error {
+	for {
+		leaderCtx, err := e.lock.TryLockContext(ctx)
+		if err == nil {
+			e.lead(ctx, leaderCtx)
+		}
+
+		// 竞选失败或者刚失去 leader 身份，等待一段时间再竞选
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(e.retryPeriod)):
+		}
+	}
+}
+
+// lead 持有租约直到租约丢失或 ctx 取消
+func (e *Elector) lead(ctx, leaderCtx context.Context) {
+	e.mu.Lock()
+	onStarted, onStopped := e.onStarted, e.onStopped
+	e.mu.Unlock()
+
+	e.leader.Store(true)
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		if onStarted != nil {
+			onStarted(leaderCtx)
+		}
+	}()
+
+	<-leaderCtx.Done()
+	e.leader.Store(false)
+	// 等这一任期的回调退出，再通知 onStopped 和重新竞选
+	<-started
+	if ctx.Err() != nil {
+		// 主动退出，释放租约
+		uctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		e.lock.Unlock(uctx)
+		cancel()
+	}
+	if onStopped != nil {
+		onStopped()
+	}
+}
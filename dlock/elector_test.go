@@ -0,0 +1,126 @@
+package dlock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElector(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+
+	started := make(chan string, 2)
+	stopped := make(chan string, 2)
+	newElector := func(id string) *Elector {
+		e := NewElector(client, "leader", id, 300*time.Millisecond)
+		e.OnStartedLeading(func(ctx context.Context) { started <- id })
+		e.OnStoppedLeading(func() { stopped <- id })
+		return e
+	}
+	e1 := newElector("node-1")
+	e2 := newElector("node-2")
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	done1 := make(chan error, 1)
+	go func() { done1 <- e1.Run(ctx1) }()
+	assert.Equal(t, "node-1", <-started)
+	assert.True(t, e1.IsLeader())
+
+	ctx2, cancel2 := context.WithCancel(ctx)
+	defer cancel2()
+	done2 := make(chan error, 1)
+	go func() { done2 <- e2.Run(ctx2) }()
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, e2.IsLeader())
+	leader, err := e2.Leader(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", leader)
+
+	// node-1 resigns, node-2 takes over without waiting for the lease to expire
+	cancel1()
+	assert.ErrorIs(t, <-done1, context.Canceled)
+	assert.Equal(t, "node-1", <-stopped)
+	assert.False(t, e1.IsLeader())
+	select {
+	case id := <-started:
+		assert.Equal(t, "node-2", id)
+	case <-time.After(time.Second):
+		t.Fatal("node-2 did not take over")
+	}
+	assert.True(t, e2.IsLeader())
+
+	// the lease is taken away, node-2 steps down
+	s.Set("leader", "node-3")
+	select {
+	case id := <-stopped:
+		assert.Equal(t, "node-2", id)
+	case <-time.After(time.Second):
+		t.Fatal("node-2 did not step down")
+	}
+	assert.False(t, e2.IsLeader())
+	cancel2()
+	assert.ErrorIs(t, <-done2, context.Canceled)
+}
+
+func TestElectorCallbackOrder(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		events []string
+		active int
+	)
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+	e := NewElector(client, "leader", "node-1", 300*time.Millisecond)
+	e.OnStartedLeading(func(ctx context.Context) {
+		mu.Lock()
+		active++
+		overlap := active > 1
+		mu.Unlock()
+		assert.False(t, overlap, "OnStartedLeading overlapped")
+		record("started")
+		<-ctx.Done()
+		// a slow shutdown still finishes before OnStoppedLeading
+		time.Sleep(100 * time.Millisecond)
+		record("exited")
+		mu.Lock()
+		active--
+		mu.Unlock()
+	})
+	e.OnStoppedLeading(func() { record("stopped") })
+
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	waitEvents := func(n int) {
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(events) >= n
+		}, 3*time.Second, 5*time.Millisecond)
+	}
+	waitEvents(1)
+
+	// lose the lease twice, the replica campaigns again right after each loss
+	for i := 0; i < 2; i++ {
+		s.Del("leader")
+		waitEvents(3*i + 4)
+	}
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, ev := range events {
+		assert.Equal(t, []string{"started", "exited", "stopped"}[i%3], ev, events)
+	}
+}
@@ -55,7 +55,7 @@ func Example() {
 	fmt.Println("锁已释放")
 }
 
-func ExampleRedisLock_WaitLock() {
+func ExampleNewRedisLock_waitLock() {
 	// 创建一个 Redis 客户端
 	rdb := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
@@ -119,7 +119,7 @@ func ExampleRedisLock_WaitLock() {
 	wg.Wait()
 }
 
-func ExampleRedisLock_Refresh() {
+func ExampleNewRedisLock_refresh() {
 	// 创建一个 Redis 客户端
 	rdb := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
@@ -190,3 +190,33 @@ func ExampleRedisLock_Refresh() {
 		fmt.Println("锁已释放")
 	}
 }
+
+func ExampleNewRedisLock_watchdog() {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+
+	ctx := context.Background()
+
+	// 开启看门狗，每隔 expiration/3 自动续期，直到 Unlock
+	lock := NewRedisLock(rdb, "watchdog-resource", "long-task-client", 5*time.Second, WithWatchdog(1.0/3))
+
+	lockCtx, err := lock.TryLockContext(ctx)
+	if err != nil {
+		fmt.Printf("获取锁失败: %v\n", err)
+		return
+	}
+	defer lock.Unlock(ctx)
+
+	// 模拟长时间任务，锁丢失时 lockCtx 会被取消
+	for i := 1; i <= 10; i++ {
+		select {
+		case <-time.After(1 * time.Second):
+			fmt.Printf("任务运行中: %d 秒\n", i)
+		case <-lockCtx.Done():
+			fmt.Printf("锁已丢失，任务中止: %v\n", context.Cause(lockCtx))
+			return
+		}
+	}
+	fmt.Println("任务完成")
+}
@@ -0,0 +1,143 @@
+package dlock
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// fairWaiterLease 是等待者在队列中的租期，等待者每次重试都会续期，崩溃的等待者过期后被移出队列
+	fairWaiterLease = 2 * time.Second
+	// 兜底重试的退避区间，收到唤醒消息时会立即重试
+	fairMinBackoff = 20 * time.Millisecond
+	fairMaxBackoff = 500 * time.Millisecond
+)
+
+// 公平锁使用的辅助 key
+func (rl *redisLock) queueKey() string    { return rl.key + ":queue" }
+func (rl *redisLock) timeoutsKey() string { return rl.key + ":timeouts" }
+func (rl *redisLock) seqKey() string      { return rl.key + ":seq" }
+func (rl *redisLock) wakeChannel() string { return rl.key + ":wake" }
+
+// fairTryLock 按排队顺序获取锁，只有队首（或队列为空时）才能拿到锁
+// enqueue 为 true 时获取失败会排队并续期自己的等待租期
+// fence 为 true 时获取成功后同时生成 fencing token，否则 token 为 0
+func (rl *redisLock) fairTryLock(ctx context.Context, enqueue, fence bool) (int64, error) {
+	// KEYS: 锁, 排队顺序, 等待者租期, 排队序号, fencing token, 获取时间
+	// ARGV: value, 锁过期毫秒, 等待租期毫秒, 是否排队, 是否生成 token
+	// 返回 -1 表示获取失败
+	script := luaNow + `
+	local stale = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", now)
+	for _, v in ipairs(stale) do
+		redis.call("ZREM", KEYS[2], v)
+		redis.call("ZREM", KEYS[3], v)
+	end
+	if redis.call("EXISTS", KEYS[1]) == 0 then
+		local head = redis.call("ZRANGE", KEYS[2], 0, 0)
+		if head[1] == nil or head[1] == ARGV[1] then
+			redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+			redis.call("SET", KEYS[6], now, "PX", ARGV[2])
+			redis.call("ZREM", KEYS[2], ARGV[1])
+			redis.call("ZREM", KEYS[3], ARGV[1])
+			if ARGV[5] == "1" then
+				return redis.call("INCR", KEYS[5])
+			end
+			return 0
+		end
+	end
+	if ARGV[4] == "1" then
+		if redis.call("ZSCORE", KEYS[2], ARGV[1]) == false then
+			redis.call("ZADD", KEYS[2], redis.call("INCR", KEYS[4]), ARGV[1])
+		end
+		redis.call("ZADD", KEYS[3], now + tonumber(ARGV[3]), ARGV[1])
+		for i = 2, 4 do
+			redis.call("PEXPIRE", KEYS[i], tonumber(ARGV[3]) * 2)
+		end
+	end
+	return -1
+	`
+
+	keys := []string{rl.key, rl.queueKey(), rl.timeoutsKey(), rl.seqKey(), rl.fencingKey(), rl.acquiredKey()}
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, keys, rl.value, millis, fairWaiterLease.Milliseconds(), flag(enqueue), flag(fence)).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 {
+		return 0, ErrLockAcquireFailed
+	}
+
+	return n, nil
+}
+
+// fairWaitLock 排队等待锁，释放锁时通过 pub/sub 唤醒，消息丢失时靠带抖动的退避重试兜底
+func (rl *redisLock) fairWaitLock(ctx context.Context, timeout time.Duration, fence bool) (int64, error) {
+	// 先订阅再排队，避免错过排队之后的唤醒
+	sub := rl.client.Subscribe(ctx, rl.wakeChannel())
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return 0, err
+	}
+	wake := sub.Channel()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	backoff := fairMinBackoff
+	for {
+		token, err := rl.fairTryLock(ctx, true, fence)
+		if err == nil {
+			return token, nil
+		}
+		if err != ErrLockAcquireFailed {
+			rl.leaveQueue()
+			return 0, err
+		}
+
+		select {
+		case <-ctx.Done():
+			rl.leaveQueue()
+			return 0, ctx.Err()
+		case <-deadline.C:
+			rl.leaveQueue()
+			return 0, ErrLockAcquireFailed
+		case <-wake:
+			backoff = fairMinBackoff
+		case <-time.After(jitter(backoff)):
+			backoff *= 2
+			if backoff > fairMaxBackoff {
+				backoff = fairMaxBackoff
+			}
+		}
+	}
+}
+
+// leaveQueue 放弃等待时退出队列，并唤醒后面的等待者
+func (rl *redisLock) leaveQueue() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pipe := rl.client.TxPipeline()
+	pipe.ZRem(ctx, rl.queueKey(), rl.value)
+	pipe.ZRem(ctx, rl.timeoutsKey(), rl.value)
+	pipe.Publish(ctx, rl.wakeChannel(), rl.value)
+	pipe.Exec(ctx)
+}
+
+// wakeWaiters 释放锁后唤醒等待者
+func (rl *redisLock) wakeWaiters(ctx context.Context) {
+	rl.client.Publish(ctx, rl.wakeChannel(), rl.value)
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// jitter 返回 [d/2, d) 之间的随机时长
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
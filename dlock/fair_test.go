@@ -0,0 +1,92 @@
+package dlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairWaitLock(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	newLock := func(value string) *redisLock {
+		return NewRedisLock(client, "fair", value, time.Minute, WithFairWait())
+	}
+
+	holder := newLock("holder")
+	assert.NoError(t, holder.TryLock(ctx))
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		value := fmt.Sprintf("waiter-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := newLock(value)
+			if !assert.NoError(t, l.WaitLock(ctx, 5*time.Second)) {
+				return
+			}
+			mu.Lock()
+			order = append(order, value)
+			mu.Unlock()
+			assert.NoError(t, l.Unlock(ctx))
+		}()
+		// let the waiter take its ticket before the next one arrives
+		assert.Eventually(t, func() bool {
+			return client.ZCard(ctx, "fair:queue").Val() == int64(i+1)
+		}, time.Second, 5*time.Millisecond)
+	}
+
+	// a TryLock does not jump the queue
+	assert.NoError(t, holder.Unlock(ctx))
+	assert.ErrorIs(t, newLock("intruder").TryLock(ctx), ErrLockAcquireFailed)
+
+	start := time.Now()
+	wg.Wait()
+	assert.Equal(t, []string{"waiter-0", "waiter-1", "waiter-2", "waiter-3"}, order)
+	// waiters are woken by pub/sub, not by the fallback backoff
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestFairWaitLockTimeout(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	holder := NewRedisLock(client, "fair-timeout", "holder", time.Minute, WithFairWait())
+	waiter := NewRedisLock(client, "fair-timeout", "waiter", time.Minute, WithFairWait())
+
+	assert.NoError(t, holder.TryLock(ctx))
+	assert.ErrorIs(t, waiter.WaitLock(ctx, 100*time.Millisecond), ErrLockAcquireFailed)
+	// the waiter that gave up left the queue
+	assert.Equal(t, int64(0), client.ZCard(ctx, "fair-timeout:queue").Val())
+
+	assert.NoError(t, holder.Unlock(ctx))
+	assert.NoError(t, waiter.TryLock(ctx))
+}
+
+func TestFairWaiterLeaseUsesRedisClock(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	holder := NewRedisLock(client, "fair", "holder", time.Minute, WithFairWait())
+	waiter := NewRedisLock(client, "fair", "waiter", time.Minute, WithFairWait())
+	other := NewRedisLock(client, "fair", "other", time.Minute, WithFairWait())
+
+	assert.NoError(t, holder.TryLock(ctx))
+	_, err := waiter.fairTryLock(ctx, true, false)
+	assert.ErrorIs(t, err, ErrLockAcquireFailed)
+	assert.NoError(t, holder.Unlock(ctx))
+
+	// the waiter lease is live on the Redis clock, whatever the client clocks say
+	assert.ErrorIs(t, other.TryLock(ctx), ErrLockAcquireFailed)
+
+	// once it runs out on the Redis clock the crashed waiter is dropped
+	s.SetTime(time.Now().Add(2 * fairWaiterLease))
+	assert.NoError(t, other.TryLock(ctx))
+}
@@ -0,0 +1,84 @@
+package dlock
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	_ IFencedLock = &redisLock{}
+)
+
+// IFencedLock 是获取锁时可以返回 fencing token 的锁
+// token 单调递增，下游写入时带上 token 并拒绝比已见过的 token 更小的请求，
+// 可以防止暂停过（GC、网络分区）的旧持有者在锁过期后继续写入
+type IFencedLock interface {
+	IDLock
+	// TryLockToken 尝试获取锁，成功时返回 fencing token
+	TryLockToken(ctx context.Context) (int64, error)
+	// WaitLockToken 获取锁，如果获取失败会重试直到超时，成功时返回 fencing token
+	WaitLockToken(ctx context.Context, timeout time.Duration) (int64, error)
+}
+
+// fencingKey 保存 fencing token 的计数器，不设置过期时间，保证锁过期后 token 依然递增
+func (rl *redisLock) fencingKey() string { return rl.key + ":fencing" }
+
+// TryLockToken 尝试获取锁，成功时返回 fencing token
+func (rl *redisLock) TryLockToken(ctx context.Context) (int64, error) {
+	token, err := rl.tryLockToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rl.startWatchdog(ctx)
+	return token, nil
+}
+
+// WaitLockToken 获取锁，如果获取失败会重试直到超时，成功时返回 fencing token
+func (rl *redisLock) WaitLockToken(ctx context.Context, timeout time.Duration) (int64, error) {
+	var (
+		token int64
+		err   error
+	)
+	if rl.opt.fair {
+		token, err = rl.fairWaitLock(ctx, timeout, true)
+	} else {
+		err = pollLock(ctx, timeout, func(ctx context.Context) error {
+			var err error
+			token, err = rl.tryLockToken(ctx)
+			return err
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+	rl.startWatchdog(ctx)
+	return token, nil
+}
+
+// tryLockToken 加锁和生成 token 在同一个 Lua 脚本中完成，token 一定属于这次加锁
+func (rl *redisLock) tryLockToken(ctx context.Context) (int64, error) {
+	if rl.opt.fair {
+		return rl.fairTryLock(ctx, false, true)
+	}
+
+	script := `
+	if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		redis.call("SET", KEYS[3], ARGV[3], "PX", ARGV[2])
+		return redis.call("INCR", KEYS[2])
+	end
+	return 0
+	`
+
+	keys := []string{rl.key, rl.fencingKey(), rl.acquiredKey()}
+	millis := int64(rl.expiration / time.Millisecond)
+	token, err := rl.client.Eval(ctx, script, keys, rl.value, millis, time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	if token == 0 {
+		return 0, ErrLockAcquireFailed
+	}
+
+	return token, nil
+}
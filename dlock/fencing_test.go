@@ -0,0 +1,42 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFencingToken(t *testing.T) {
+	for _, fair := range []bool{false, true} {
+		s, client := newTestRedis(t)
+		ctx := context.Background()
+		var ops []Option
+		if fair {
+			ops = append(ops, WithFairWait())
+		}
+		lock1 := NewRedisLock(client, "fencing", "client-1", 100*time.Millisecond, ops...)
+		lock2 := NewRedisLock(client, "fencing", "client-2", 100*time.Millisecond, ops...)
+
+		token1, err := lock1.TryLockToken(ctx)
+		assert.NoError(t, err)
+		_, err = lock2.TryLockToken(ctx)
+		assert.ErrorIs(t, err, ErrLockAcquireFailed)
+
+		// the lease of client-1 expires, client-2 gets a larger token
+		s.FastForward(time.Second)
+		token2, err := lock2.WaitLockToken(ctx, time.Second)
+		assert.NoError(t, err)
+		assert.Greater(t, token2, token1)
+		assert.ErrorIs(t, lock1.Unlock(ctx), ErrLockReleaseFailed)
+		assert.NoError(t, lock2.Unlock(ctx))
+
+		// the counter outlives the lock
+		assert.False(t, s.Exists("fencing"))
+		token3, err := lock1.WaitLockToken(ctx, time.Second)
+		assert.NoError(t, err)
+		assert.Greater(t, token3, token2)
+		assert.NoError(t, lock1.Unlock(ctx))
+	}
+}
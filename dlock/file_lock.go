@@ -0,0 +1,101 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	_ IDLock = &fileLock{}
+
+	errLockBusy = errors.New("lock is held by another process")
+)
+
+// fileLock 是基于本地文件的锁（flock / LockFileEx），适合没有服务端的命令行工具
+// 锁由操作系统持有，进程退出时自动释放，所以没有过期时间
+// 持有者会把 value 写入文件，方便查看谁持有锁
+type fileLock struct {
+	path  string
+	value string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileLock 创建一个基于本地文件的锁，文件不存在时会自动创建
+// 锁文件不会被删除，删除后两个进程可能锁住同一路径的两个不同文件
+func NewFileLock(path, value string) *fileLock {
+	return &fileLock{
+		path:  path,
+		value: value,
+	}
+}
+
+// TryLock 尝试获取锁，立即返回结果
+func (fl *fileLock) TryLock(ctx context.Context) error {
+	return fl.tryLock(ctx)
+}
+
+// WaitLock 获取锁，如果获取失败会重试直到超时
+func (fl *fileLock) WaitLock(ctx context.Context, timeout time.Duration) error {
+	return pollLock(ctx, timeout, fl.tryLock)
+}
+
+func (fl *fileLock) tryLock(ctx context.Context) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if fl.f != nil {
+		return ErrLockAcquireFailed
+	}
+
+	f, err := os.OpenFile(fl.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("open lock file fail %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLockBusy) {
+			return ErrLockAcquireFailed
+		}
+		return fmt.Errorf("lock file fail %w", err)
+	}
+
+	// 写入持有者只是为了方便排查，失败不影响加锁
+	if f.Truncate(0) == nil {
+		f.WriteAt([]byte(fl.value), 0)
+	}
+	fl.f = f
+	return nil
+}
+
+// Unlock 释放锁
+func (fl *fileLock) Unlock(ctx context.Context) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if fl.f == nil {
+		return ErrLockReleaseFailed
+	}
+
+	f := fl.f
+	fl.f = nil
+	defer f.Close()
+	f.Truncate(0)
+	if err := unlockFile(f); err != nil {
+		return fmt.Errorf("unlock file fail %w", err)
+	}
+	return nil
+}
+
+// Refresh 文件锁没有过期时间，持有锁时直接返回成功
+func (fl *fileLock) Refresh(ctx context.Context) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if fl.f == nil {
+		return ErrLockReleaseFailed
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockNotHeld 表示锁当前没有被任何人持有
+var ErrLockNotHeld = errors.New("lock is not held")
+
+// LockInfo 是锁当前的持有情况
+type LockInfo struct {
+	// Holder 是持有者的 value
+	Holder string
+	// TTL 是锁剩余的过期时间
+	TTL time.Duration
+	// AcquiredAt 是获取锁的时间，没有记录时为零值
+	AcquiredAt time.Time
+}
+
+// acquiredKey 记录获取锁的时间，过期时间和锁保持一致
+func (rl *redisLock) acquiredKey() string { return rl.key + ":acquired_at" }
+
+// Inspect 查看 NewRedisLock 创建的锁当前由谁持有，锁不存在时返回 ErrLockNotHeld
+func Inspect(ctx context.Context, client *redis.Client, key string) (*LockInfo, error) {
+	pipe := client.Pipeline()
+	holder := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	acquired := pipe.Get(ctx, (&redisLock{key: key}).acquiredKey())
+	pipe.Exec(ctx)
+
+	if err := holder.Err(); err != nil {
+		if err == redis.Nil {
+			return nil, ErrLockNotHeld
+		}
+		return nil, err
+	}
+	if err := ttl.Err(); err != nil {
+		return nil, err
+	}
+
+	info := &LockInfo{Holder: holder.Val(), TTL: ttl.Val()}
+	if millis, err := acquired.Int64(); err == nil {
+		info.AcquiredAt = time.UnixMilli(millis)
+	}
+	return info, nil
+}
@@ -0,0 +1,40 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+
+	_, err := Inspect(ctx, client, "inspect")
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+
+	for _, lock := range []*redisLock{
+		NewRedisLock(client, "inspect", "client-1", time.Second),
+		NewRedisLock(client, "inspect", "client-1", time.Second, WithFairWait()),
+	} {
+		before := time.Now().Add(-time.Millisecond)
+		assert.NoError(t, lock.TryLock(ctx))
+		info, err := Inspect(ctx, client, "inspect")
+		assert.NoError(t, err)
+		assert.Equal(t, "client-1", info.Holder)
+		assert.InDelta(t, time.Second, info.TTL, float64(100*time.Millisecond))
+		assert.WithinRange(t, info.AcquiredAt, before, time.Now())
+		assert.NoError(t, lock.Unlock(ctx))
+
+		_, err = lock.TryLockToken(ctx)
+		assert.NoError(t, err)
+		info, err = Inspect(ctx, client, "inspect")
+		assert.NoError(t, err)
+		assert.False(t, info.AcquiredAt.IsZero())
+		assert.NoError(t, lock.Unlock(ctx))
+		_, err = Inspect(ctx, client, "inspect")
+		assert.ErrorIs(t, err, ErrLockNotHeld)
+	}
+}
@@ -0,0 +1,120 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	_ IDLock = &mongoLock{}
+)
+
+// EnsureMongoLockIndex 在锁集合上创建 TTL 索引，过期的锁文档由 MongoDB 自动清理
+// TTL 清理最多有一分钟左右的延迟，加锁时会自己判断过期时间，索引只用于回收文档
+func EnsureMongoLockIndex(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: mongooptions.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// mongoLock 是基于 MongoDB 的分布式锁，每个锁是一个 _id 为锁名的文档
+// _id 的唯一索引保证同一时间只有一个持有者
+type mongoLock struct {
+	coll       *mongo.Collection
+	key        string
+	value      string
+	expiration time.Duration
+	opt        *options
+	hold       holdState
+}
+
+// NewMongoLock 创建一个基于 MongoDB 的分布式锁，coll 可以通过 mongo 包返回的 *mongo.Client 获取
+func NewMongoLock(coll *mongo.Collection, key, value string, expiration time.Duration, ops ...Option) *mongoLock {
+	return &mongoLock{
+		coll:       coll,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		opt:        newOptions(ops),
+	}
+}
+
+// TryLock 尝试获取锁，立即返回结果
+func (ml *mongoLock) TryLock(ctx context.Context) error {
+	if err := ml.tryLock(ctx); err != nil {
+		return err
+	}
+	ml.startWatchdog(ctx)
+	return nil
+}
+
+// WaitLock 获取锁，如果获取失败会重试直到超时
+func (ml *mongoLock) WaitLock(ctx context.Context, timeout time.Duration) error {
+	if err := pollLock(ctx, timeout, ml.tryLock); err != nil {
+		return err
+	}
+	ml.startWatchdog(ctx)
+	return nil
+}
+
+func (ml *mongoLock) tryLock(ctx context.Context) error {
+	// 只有文档不存在或者已经过期时才能更新，文档未过期时 upsert 会插入重复的 _id 而失败
+	// 过期时间按服务端的 $$NOW 计算，不依赖各个客户端的时钟，需要 MongoDB 4.2 及以上
+	filter := bson.M{"_id": ml.key, "$expr": bson.M{"$lte": bson.A{"$expires_at", "$$NOW"}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"value": ml.value, "expires_at": ml.expiresAt()}}}}
+	_, err := ml.coll.UpdateOne(ctx, filter, update, mongooptions.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLockAcquireFailed
+	}
+	return err
+}
+
+// expiresAt 是服务端当前时间加上过期时间的表达式
+func (ml *mongoLock) expiresAt() bson.M {
+	return bson.M{"$add": bson.A{"$$NOW", ml.expiration.Milliseconds()}}
+}
+
+func (ml *mongoLock) startWatchdog(ctx context.Context) {
+	if ml.opt.watchdogRatio > 0 {
+		ml.hold.start(context.WithoutCancel(ctx), ml.expiration, ml.opt.watchdogRatio, ml.Refresh)
+	}
+}
+
+// Unlock 释放锁，只删除自己持有的文档
+func (ml *mongoLock) Unlock(ctx context.Context) error {
+	ml.hold.stop()
+
+	res, err := ml.coll.DeleteOne(ctx, bson.M{"_id": ml.key, "value": ml.value})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// Refresh 刷新锁的过期时间，锁已经过期时返回 ErrLockReleaseFailed
+func (ml *mongoLock) Refresh(ctx context.Context) error {
+	filter := bson.M{"_id": ml.key, "value": ml.value, "$expr": bson.M{"$gt": bson.A{"$expires_at", "$$NOW"}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"expires_at": ml.expiresAt()}}}}
+	res, err := ml.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	ml.hold.extend(ml.expiration)
+	return nil
+}
@@ -0,0 +1,86 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// nextCommand returns the next command sent to the mock deployment
+func nextCommand(mt *mtest.T) bson.Raw {
+	e := mt.GetStartedEvent()
+	require.NotNil(mt, e)
+	return e.Command
+}
+
+// fieldJSON returns a field of cmd as relaxed extended JSON wrapped in {"v": ...}
+func fieldJSON(mt *mtest.T, cmd bson.Raw, path ...string) string {
+	v, err := cmd.LookupErr(path...)
+	require.NoError(mt, err, cmd.String())
+	out, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+	require.NoError(mt, err)
+	return string(out)
+}
+
+// TestMongoLockCommands runs the lock against a mock deployment and checks the commands it sends,
+// TestConformanceMongo runs the same lock against a real MongoDB
+func TestMongoLockCommands(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ctx := context.Background()
+
+	mt.Run("acquire", func(mt *mtest.T) {
+		l := NewMongoLock(mt.Coll, "k", "v", 1500*time.Millisecond)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+		assert.NoError(mt, l.TryLock(ctx))
+		cmd := nextCommand(mt)
+
+		// the lock is taken over only when missing or expired on the server clock
+		assert.JSONEq(mt, `{"v":{"_id":"k","$expr":{"$lte":["$expires_at","$$NOW"]}}}`, fieldJSON(mt, cmd, "updates", "0", "q"))
+		// the update is a pipeline so $$NOW is evaluated by the server
+		assert.JSONEq(mt, `{"v":[{"$set":{"value":"v","expires_at":{"$add":["$$NOW",1500]}}}]}`, fieldJSON(mt, cmd, "updates", "0", "u"))
+		assert.JSONEq(mt, `{"v":true}`, fieldJSON(mt, cmd, "updates", "0", "upsert"))
+	})
+
+	mt.Run("held", func(mt *mtest.T) {
+		l := NewMongoLock(mt.Coll, "k", "v", time.Second)
+		// a live lock does not match, the upsert collides on _id
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}))
+		assert.ErrorIs(mt, l.TryLock(ctx), ErrLockAcquireFailed)
+	})
+
+	mt.Run("refresh", func(mt *mtest.T) {
+		l := NewMongoLock(mt.Coll, "k", "v", time.Second)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+		assert.NoError(mt, l.Refresh(ctx))
+		cmd := nextCommand(mt)
+		assert.JSONEq(mt, `{"v":{"_id":"k","value":"v","$expr":{"$gt":["$expires_at","$$NOW"]}}}`, fieldJSON(mt, cmd, "updates", "0", "q"))
+		assert.JSONEq(mt, `{"v":[{"$set":{"expires_at":{"$add":["$$NOW",1000]}}}]}`, fieldJSON(mt, cmd, "updates", "0", "u"))
+
+		// an expired or foreign lock matches nothing
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+		assert.ErrorIs(mt, l.Refresh(ctx), ErrLockReleaseFailed)
+	})
+
+	mt.Run("unlock", func(mt *mtest.T) {
+		l := NewMongoLock(mt.Coll, "k", "v", time.Second)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+		assert.NoError(mt, l.Unlock(ctx))
+		cmd := nextCommand(mt)
+		assert.JSONEq(mt, `{"v":{"_id":"k","value":"v"}}`, fieldJSON(mt, cmd, "deletes", "0", "q"))
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))
+		assert.ErrorIs(mt, l.Unlock(ctx), ErrLockReleaseFailed)
+	})
+
+	mt.Run("ttl index", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		assert.NoError(mt, EnsureMongoLockIndex(ctx, mt.Coll))
+		cmd := nextCommand(mt)
+		assert.JSONEq(mt, `{"v":{"key":{"expires_at":1},"name":"expires_at_1","expireAfterSeconds":0}}`, fieldJSON(mt, cmd, "indexes", "0"))
+	})
+}
@@ -0,0 +1,167 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	_ IDLock = &mysqlLock{}
+)
+
+// IMySQL 是 mysql 包中 IMySQL 的子集，锁只使用写库
+type IMySQL interface {
+	GetWriteDB() *gorm.DB
+}
+
+// mysqlLockRow 是锁在 MySQL 中的一行，lock_key 是主键，expires_at 是按数据库时钟计算的毫秒时间戳
+type mysqlLockRow struct {
+	Key       string `gorm:"column:lock_key;primaryKey;size:191"`
+	Value     string `gorm:"column:value;size:191;not null"`
+	ExpiresAt int64  `gorm:"column:expires_at;not null;index"`
+}
+
+func (mysqlLockRow) TableName() string {
+	return "dlocks"
+}
+
+// MigrateMySQLLock 创建锁使用的 dlocks 表
+func MigrateMySQLLock(db IMySQL) error {
+	return db.GetWriteDB().AutoMigrate(&mysqlLockRow{})
+}
+
+// mysqlLock 是基于 MySQL 的分布式锁，每个锁是 dlocks 表中带过期时间的一行
+type mysqlLock struct {
+	db         IMySQL
+	key        string
+	value      string
+	expiration time.Duration
+	opt        *options
+	hold       holdState
+}
+
+// NewMySQLLock 创建一个基于 MySQL 的分布式锁，使用前需要先调用 MigrateMySQLLock 建表
+func NewMySQLLock(db IMySQL, key, value string, expiration time.Duration, ops ...Option) *mysqlLock {
+	return &mysqlLock{
+		db:         db,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		opt:        newOptions(ops),
+	}
+}
+
+// TryLock 尝试获取锁，立即返回结果
+func (ml *mysqlLock) TryLock(ctx context.Context) error {
+	if err := ml.tryLock(ctx); err != nil {
+		return err
+	}
+	ml.startWatchdog(ctx)
+	return nil
+}
+
+// WaitLock 获取锁，如果获取失败会重试直到超时
+func (ml *mysqlLock) WaitLock(ctx context.Context, timeout time.Duration) error {
+	if err := pollLock(ctx, timeout, ml.tryLock); err != nil {
+		return err
+	}
+	ml.startWatchdog(ctx)
+	return nil
+}
+
+// dbNowMillis 返回数据库当前毫秒时间戳的 SQL 表达式，过期时间都按数据库时钟计算，不依赖各个客户端的时钟
+func dbNowMillis(db *gorm.DB) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "CAST(ROUND((julianday('now') - 2440587.5) * 86400000) AS INTEGER)"
+	}
+	return "CAST(UNIX_TIMESTAMP(NOW(3)) * 1000 AS SIGNED)"
+}
+
+// insertIgnore 返回主键冲突时忽略插入的子句
+// MySQL 的 ON DUPLICATE KEY UPDATE 在开启 clientFoundRows 时冲突也会返回影响 1 行，所以使用 INSERT IGNORE
+func insertIgnore(db *gorm.DB) clause.Expression {
+	if db.Dialector.Name() == "mysql" {
+		return clause.Insert{Modifier: "IGNORE"}
+	}
+	return clause.OnConflict{DoNothing: true}
+}
+
+func (ml *mysqlLock) tryLock(ctx context.Context) error {
+	db := ml.db.GetWriteDB().WithContext(ctx)
+	now := dbNowMillis(db)
+
+	// 先删除已经过期的锁，再插入，主键冲突说明锁被其他人持有
+	err := db.Where("lock_key = ? AND expires_at <= "+now, ml.key).Delete(&mysqlLockRow{}).Error
+	if err != nil {
+		return err
+	}
+
+	res := db.Model(&mysqlLockRow{}).Clauses(insertIgnore(db)).Create(map[string]any{
+		"lock_key":   ml.key,
+		"value":      ml.value,
+		"expires_at": gorm.Expr(now+" + ?", ml.expiration.Milliseconds()),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected != 1 {
+		return ErrLockAcquireFailed
+	}
+
+	return nil
+}
+
+func (ml *mysqlLock) startWatchdog(ctx context.Context) {
+	if ml.opt.watchdogRatio > 0 {
+		ml.hold.start(context.WithoutCancel(ctx), ml.expiration, ml.opt.watchdogRatio, ml.Refresh)
+	}
+}
+
+// Unlock 释放锁，只删除自己持有的行
+func (ml *mysqlLock) Unlock(ctx context.Context) error {
+	ml.hold.stop()
+
+	res := ml.db.GetWriteDB().WithContext(ctx).
+		Where("lock_key = ? AND value = ?", ml.key, ml.value).
+		Delete(&mysqlLockRow{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// Refresh 刷新锁的过期时间，锁已经过期时返回 ErrLockReleaseFailed
+func (ml *mysqlLock) Refresh(ctx context.Context) error {
+	db := ml.db.GetWriteDB().WithContext(ctx)
+	now := dbNowMillis(db)
+	where := "lock_key = ? AND value = ? AND expires_at > " + now
+	res := db.Model(&mysqlLockRow{}).
+		Where(where, ml.key, ml.value).
+		Update("expires_at", gorm.Expr(now+" + ?", ml.expiration.Milliseconds()))
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected != 1 {
+		// 同一毫秒内刷新时新旧值相同，MySQL 不计入影响行数，需要再确认锁是否还在
+		var n int64
+		if err := db.Model(&mysqlLockRow{}).Where(where, ml.key, ml.value).Count(&n).Error; err != nil {
+			return err
+		}
+		if n != 1 {
+			return ErrLockReleaseFailed
+		}
+	}
+
+	ml.hold.extend(ml.expiration)
+	return nil
+}
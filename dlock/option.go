@@ -0,0 +1,77 @@
+package dlock
+
+import "time"
+
+// Option 锁的可选配置
+type Option func(*options)
+
+type options struct {
+	// watchdogRatio 大于 0 时开启自动续期，续期间隔为 expiration * watchdogRatio
+	watchdogRatio float64
+	// fair 为 true 时 WaitLock 按到达顺序排队获取锁
+	fair bool
+	// nodeTimeout 是 Redlock 单个节点的操作超时
+	nodeTimeout time.Duration
+
+	// 以下选项只用于 Do
+	waitTimeout time.Duration
+	onWait      func(wait time.Duration, err error)
+	onHold      func(hold time.Duration)
+}
+
+func newOptions(ops []Option) *options {
+	o := &options{}
+	for _, op := range ops {
+		op(o)
+	}
+	return o
+}
+
+// WithWatchdog 开启自动续期（看门狗）
+// 获取锁后每隔 expiration * ratio 刷新一次过期时间，直到 Unlock
+// ratio 需要在 (0, 1) 之间，否则使用默认值 1/3
+func WithWatchdog(ratio float64) Option {
+	return func(o *options) {
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 1.0 / 3
+		}
+		o.watchdogRatio = ratio
+	}
+}
+
+// WithFairWait 开启公平等待，仅 NewRedisLock 支持
+// WaitLock 按到达顺序排队，释放锁时通过 pub/sub 立即唤醒等待者，不再每 50ms 轮询
+// TryLock 也不会插队：有人排队时只有队首能获取锁
+func WithFairWait() Option {
+	return func(o *options) {
+		o.fair = true
+	}
+}
+
+// WithNodeTimeout 设置 Redlock 单个节点的操作超时，默认 50ms，且不超过过期时间的 1/10
+func WithNodeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.nodeTimeout = timeout
+	}
+}
+
+// WithWaitTimeout 设置 Do 等待锁的超时时间，仅 Do 支持，不设置时 Do 只尝试一次
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.waitTimeout = timeout
+	}
+}
+
+// WithWaitHook 设置 Do 获取锁之后的回调，参数是等待时间和获取结果，仅 Do 支持
+func WithWaitHook(hook func(wait time.Duration, err error)) Option {
+	return func(o *options) {
+		o.onWait = hook
+	}
+}
+
+// WithHoldHook 设置 Do 释放锁之后的回调，参数是持有时间，仅 Do 支持
+func WithHoldHook(hook func(hold time.Duration)) Option {
+	return func(o *options) {
+		o.onHold = hook
+	}
+}
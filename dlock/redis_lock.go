@@ -13,36 +13,81 @@ var (
 	ErrLockAcquireFailed = errors.New("failed to acquire lock")
 	// ErrLockReleaseFailed 表示锁释放失败
 	ErrLockReleaseFailed = errors.New("failed to release lock")
+	// ErrLockLost 表示持有期间锁已过期或被其他客户端获取，是锁上下文的取消原因
+	ErrLockLost = errors.New("lock lost")
 )
 
+// luaNow 是 Lua 脚本的开头，用 Redis 服务端的时间计算当前毫秒 now
+// 租期和过期判断都使用同一个时钟，客户端之间的时钟偏差不会影响互斥
+const luaNow = `
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+`
+
 // RedisLock 是一个基于 Redis 的分布式锁实现
 type redisLock struct {
 	client     *redis.Client
 	key        string
 	value      string
 	expiration time.Duration
+	opt        *options
+	hold       holdState
 }
 
 // NewRedisLock 创建一个新的 Redis 分布式锁
-func NewRedisLock(client *redis.Client, key, value string, expiration time.Duration) *redisLock {
+func NewRedisLock(client *redis.Client, key, value string, expiration time.Duration, ops ...Option) *redisLock {
 	return &redisLock{
 		client:     client,
 		key:        key,
 		value:      value,
 		expiration: expiration,
+		opt:        newOptions(ops),
 	}
 }
 
 // TryLock 尝试获取锁，立即返回结果
+// 开启看门狗时续期持续到 Unlock，不受 ctx 取消的影响
 func (rl *redisLock) TryLock(ctx context.Context) error {
+	if err := rl.tryLock(ctx); err != nil {
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+// TryLockContext 尝试获取锁，成功时返回持有锁期间的上下文
+// 上下文在 Unlock、锁过期或看门狗续期失败时取消，context.Cause 为 ErrLockLost 表示锁已丢失
+// 上下文派生自 ctx，ctx 取消时看门狗也会停止续期
+func (rl *redisLock) TryLockContext(ctx context.Context) (context.Context, error) {
+	if err := rl.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	return rl.hold.start(ctx, rl.expiration, rl.opt.watchdogRatio, rl.Refresh), nil
+}
+
+func (rl *redisLock) tryLock(ctx context.Context) error {
+	if rl.opt.fair {
+		_, err := rl.fairTryLock(ctx, false, false)
+		return err
+	}
+
 	// 使用 Redis SET NX 命令尝试设置锁
-	// NX 表示只有当 key 不存在时才会设置成功
-	success, err := rl.client.SetNX(ctx, rl.key, rl.value, rl.expiration).Result()
+	// NX 表示只有当 key 不存在时才会设置成功，成功后记录获取时间供 Inspect 使用
+	script := `
+	if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		redis.call("SET", KEYS[2], ARGV[3], "PX", ARGV[2])
+		return 1
+	end
+	return 0
+	`
+
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, []string{rl.key, rl.acquiredKey()}, rl.value, millis, time.Now().UnixMilli()).Int64()
 	if err != nil {
 		return err
 	}
 
-	if !success {
+	if n != 1 {
 		return ErrLockAcquireFailed
 	}
 
@@ -51,46 +96,51 @@ func (rl *redisLock) TryLock(ctx context.Context) error {
 
 // WaitLock 获取锁，如果获取失败会重试直到超时
 func (rl *redisLock) WaitLock(ctx context.Context, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for {
-		// 尝试获取锁
-		err := rl.TryLock(ctx)
-		if err == nil {
-			return nil
-		}
-
-		if err != ErrLockAcquireFailed {
-			return err
-		}
-
-		// 检查是否超时
-		if time.Now().After(deadline) {
-			return ErrLockAcquireFailed
-		}
-
-		// 等待一段时间后重试
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(50 * time.Millisecond):
-			// 继续尝试
-		}
+	if err := rl.waitLock(ctx, timeout); err != nil {
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+// WaitLockContext 获取锁，如果获取失败会重试直到超时，成功时返回持有锁期间的上下文
+func (rl *redisLock) WaitLockContext(ctx context.Context, timeout time.Duration) (context.Context, error) {
+	if err := rl.waitLock(ctx, timeout); err != nil {
+		return nil, err
+	}
+	return rl.hold.start(ctx, rl.expiration, rl.opt.watchdogRatio, rl.Refresh), nil
+}
+
+func (rl *redisLock) waitLock(ctx context.Context, timeout time.Duration) error {
+	if rl.opt.fair {
+		_, err := rl.fairWaitLock(ctx, timeout, false)
+		return err
+	}
+	return pollLock(ctx, timeout, rl.tryLock)
+}
+
+func (rl *redisLock) startWatchdog(ctx context.Context) {
+	if rl.opt.watchdogRatio > 0 {
+		rl.hold.start(context.WithoutCancel(ctx), rl.expiration, rl.opt.watchdogRatio, rl.Refresh)
 	}
 }
 
 // Unlock 释放锁
 func (rl *redisLock) Unlock(ctx context.Context) error {
+	rl.hold.stop()
+
 	// 使用 Lua 脚本保证原子性操作
 	// 只有当锁的值匹配时才释放锁，防止释放其他客户端的锁
 	script := `
 	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		redis.call("DEL", KEYS[2])
 		return redis.call("DEL", KEYS[1])
 	else
 		return 0
 	end
 	`
 
-	result, err := rl.client.Eval(ctx, script, []string{rl.key}, rl.value).Result()
+	result, err := rl.client.Eval(ctx, script, []string{rl.key, rl.acquiredKey()}, rl.value).Result()
 	if err != nil {
 		return err
 	}
@@ -99,6 +149,9 @@ func (rl *redisLock) Unlock(ctx context.Context) error {
 		return ErrLockReleaseFailed
 	}
 
+	if rl.opt.fair {
+		rl.wakeWaiters(ctx)
+	}
 	return nil
 }
 
@@ -108,6 +161,7 @@ func (rl *redisLock) Refresh(ctx context.Context) error {
 	// 只有当锁的值匹配时才刷新过期时间
 	script := `
 	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		redis.call("PEXPIRE", KEYS[2], ARGV[2])
 		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
 	else
 		return 0
@@ -115,7 +169,7 @@ func (rl *redisLock) Refresh(ctx context.Context) error {
 	`
 
 	millis := int64(rl.expiration / time.Millisecond)
-	result, err := rl.client.Eval(ctx, script, []string{rl.key}, rl.value, millis).Result()
+	result, err := rl.client.Eval(ctx, script, []string{rl.key, rl.acquiredKey()}, rl.value, millis).Result()
 	if err != nil {
 		return err
 	}
@@ -124,5 +178,6 @@ func (rl *redisLock) Refresh(ctx context.Context) error {
 		return ErrLockReleaseFailed
 	}
 
+	rl.hold.extend(rl.expiration)
 	return nil
 }
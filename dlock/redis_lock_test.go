@@ -0,0 +1,66 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return s, client
+}
+
+func TestRedisLockWatchdog(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	lock := NewRedisLock(client, "watchdog", "client-1", 300*time.Millisecond, WithWatchdog(1.0/3))
+
+	lockCtx, err := lock.TryLockContext(ctx)
+	assert.NoError(t, err)
+
+	// miniredis only expires keys on FastForward, so watch the ttl being reset instead
+	s.FastForward(250 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return s.TTL("watchdog") > 250*time.Millisecond
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, lockCtx.Err())
+
+	// another client took over the key, the renewal fails and the holder is told
+	s.Set("watchdog", "client-2")
+	select {
+	case <-lockCtx.Done():
+		assert.ErrorIs(t, context.Cause(lockCtx), ErrLockLost)
+	case <-time.After(time.Second):
+		t.Fatal("lock context is not cancelled")
+	}
+	assert.ErrorIs(t, lock.Unlock(ctx), ErrLockReleaseFailed)
+}
+
+func TestRedisLockContext(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	lock := NewRedisLock(client, "ctx", "client-1", 100*time.Millisecond)
+
+	lockCtx, err := lock.WaitLockContext(ctx, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock(ctx))
+	<-lockCtx.Done()
+	assert.ErrorIs(t, context.Cause(lockCtx), context.Canceled)
+
+	// without the watchdog the context ends with the lease
+	lockCtx, err = lock.TryLockContext(ctx)
+	assert.NoError(t, err)
+	select {
+	case <-lockCtx.Done():
+		assert.ErrorIs(t, context.Cause(lockCtx), ErrLockLost)
+	case <-time.After(time.Second):
+		t.Fatal("lock context outlived the lease")
+	}
+}
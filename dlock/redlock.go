@@ -0,0 +1,151 @@
+package dlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ IDLock = &redLock{}
+)
+
+const (
+	// redLockClockDrift 是时钟漂移系数，有效期需要扣除 expiration * redLockClockDrift + 2ms
+	redLockClockDrift = 0.01
+	// defaultNodeTimeout 是单个节点加锁的默认超时时间，远小于锁的过期时间，避免在故障节点上等待太久
+	defaultNodeTimeout = 50 * time.Millisecond
+)
+
+// redLock 是 Redlock 算法的实现，在 N 个相互独立的 Redis 节点上加锁
+// 只有在多数节点上加锁成功，并且扣除耗时和时钟漂移后锁仍然有效，才算获取成功
+type redLock struct {
+	nodes       []*redisLock
+	quorum      int
+	expiration  time.Duration
+	nodeTimeout time.Duration
+	opt         *options
+	hold        holdState
+}
+
+// NewRedLock 创建一个多节点的 Redlock 分布式锁，clients 应该是相互独立的 Redis 实例（不是主从）
+func NewRedLock(clients []*redis.Client, key, value string, expiration time.Duration, ops ...Option) *redLock {
+	nodes := make([]*redisLock, len(clients))
+	for i, c := range clients {
+		nodes[i] = NewRedisLock(c, key, value, expiration)
+	}
+	opt := newOptions(ops)
+	nodeTimeout := opt.nodeTimeout
+	if nodeTimeout <= 0 {
+		nodeTimeout = defaultNodeTimeout
+	}
+	if nodeTimeout > expiration/10 {
+		nodeTimeout = expiration / 10
+	}
+	return &redLock{
+		nodes:       nodes,
+		quorum:      len(clients)/2 + 1,
+		expiration:  expiration,
+		nodeTimeout: nodeTimeout,
+		opt:         opt,
+	}
+}
+
+// TryLock 尝试在多数节点上获取锁，立即返回结果
+func (rl *redLock) TryLock(ctx context.Context) error {
+	if err := rl.tryLock(ctx); err != nil {
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+// WaitLock 获取锁，如果获取失败会重试直到超时
+func (rl *redLock) WaitLock(ctx context.Context, timeout time.Duration) error {
+	if err := pollLock(ctx, timeout, rl.tryLock); err != nil {
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+func (rl *redLock) tryLock(ctx context.Context) error {
+	start := time.Now()
+	ok := rl.each(ctx, func(ctx context.Context, node *redisLock) error {
+		return node.tryLock(ctx)
+	})
+	if ok >= rl.quorum && rl.validity(start) > 0 {
+		return nil
+	}
+
+	// 没有拿到多数节点，释放已经加上的锁
+	rl.each(context.WithoutCancel(ctx), func(ctx context.Context, node *redisLock) error {
+		return node.Unlock(ctx)
+	})
+	return ErrLockAcquireFailed
+}
+
+func (rl *redLock) startWatchdog(ctx context.Context) {
+	if rl.opt.watchdogRatio > 0 {
+		rl.hold.start(context.WithoutCancel(ctx), rl.expiration, rl.opt.watchdogRatio, rl.Refresh)
+	}
+}
+
+// Unlock 在所有节点上释放锁，包括加锁失败的节点
+// 没有任何节点释放成功时返回 ErrLockReleaseFailed
+func (rl *redLock) Unlock(ctx context.Context) error {
+	rl.hold.stop()
+
+	ok := rl.each(ctx, func(ctx context.Context, node *redisLock) error {
+		return node.Unlock(ctx)
+	})
+	if ok == 0 {
+		return ErrLockReleaseFailed
+	}
+	return nil
+}
+
+// Refresh 在所有节点上刷新锁的过期时间，多数节点刷新成功才算成功
+func (rl *redLock) Refresh(ctx context.Context) error {
+	start := time.Now()
+	ok := rl.each(ctx, func(ctx context.Context, node *redisLock) error {
+		return node.Refresh(ctx)
+	})
+	if ok >= rl.quorum && rl.validity(start) > 0 {
+		return nil
+	}
+	return ErrLockReleaseFailed
+}
+
+// validity 返回从 start 开始计算，扣除耗时和时钟漂移后锁的剩余有效期
+func (rl *redLock) validity(start time.Time) time.Duration {
+	drift := time.Duration(float64(rl.expiration)*redLockClockDrift) + 2*time.Millisecond
+	return rl.expiration - time.Since(start) - drift
+}
+
+// each 并发地在每个节点上执行 f，返回成功的节点数
+func (rl *redLock) each(ctx context.Context, f func(ctx context.Context, node *redisLock) error) int {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		ok int
+	)
+	for _, node := range rl.nodes {
+		wg.Add(1)
+		go func(node *redisLock) {
+			defer wg.Done()
+			nctx, cancel := context.WithTimeout(ctx, rl.nodeTimeout)
+			defer cancel()
+			if err := f(nctx, node); err != nil {
+				return
+			}
+			mu.Lock()
+			ok++
+			mu.Unlock()
+		}(node)
+	}
+	wg.Wait()
+	return ok
+}
@@ -0,0 +1,62 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisNodes(t *testing.T, n int) ([]*miniredis.Miniredis, []*redis.Client) {
+	servers := make([]*miniredis.Miniredis, n)
+	clients := make([]*redis.Client, n)
+	for i := range servers {
+		servers[i], clients[i] = newTestRedis(t)
+	}
+	return servers, clients
+}
+
+func TestRedLock(t *testing.T) {
+	servers, clients := newTestRedisNodes(t, 3)
+	ctx := context.Background()
+	lock1 := NewRedLock(clients, "redlock", "client-1", time.Second)
+	lock2 := NewRedLock(clients, "redlock", "client-2", time.Second)
+
+	assert.NoError(t, lock1.TryLock(ctx))
+	for _, s := range servers {
+		v, _ := s.Get("redlock")
+		assert.Equal(t, "client-1", v)
+	}
+	assert.ErrorIs(t, lock2.TryLock(ctx), ErrLockAcquireFailed)
+	assert.NoError(t, lock1.Refresh(ctx))
+	assert.NoError(t, lock1.Unlock(ctx))
+	for _, s := range servers {
+		assert.False(t, s.Exists("redlock"))
+	}
+	assert.ErrorIs(t, lock1.Unlock(ctx), ErrLockReleaseFailed)
+}
+
+func TestRedLockQuorum(t *testing.T) {
+	servers, clients := newTestRedisNodes(t, 3)
+	ctx := context.Background()
+	lock := NewRedLock(clients, "redlock", "client-1", time.Second)
+
+	// another client holds the key on a majority, the partial lock must be released
+	servers[0].Set("redlock", "client-2")
+	servers[1].Set("redlock", "client-2")
+	assert.ErrorIs(t, lock.TryLock(ctx), ErrLockAcquireFailed)
+	assert.False(t, servers[2].Exists("redlock"))
+
+	// one node down still leaves a majority
+	servers[0].Del("redlock")
+	servers[1].Del("redlock")
+	servers[2].Close()
+	assert.NoError(t, lock.WaitLock(ctx, time.Second))
+	assert.NoError(t, lock.Unlock(ctx))
+
+	servers[1].Close()
+	assert.ErrorIs(t, lock.WaitLock(ctx, 200*time.Millisecond), ErrLockAcquireFailed)
+}
@@ -0,0 +1,136 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ IDLock = &reentrantLock{}
+)
+
+// reentrantLock 是可重入的 Redis 分布式锁
+// 锁保存在一个 hash 中，field 是持有者的 value，值是持有次数
+// 同一个持有者可以多次获取，只有最后一次 Unlock 才会删除锁
+type reentrantLock struct {
+	client     *redis.Client
+	key        string
+	value      string
+	expiration time.Duration
+	opt        *options
+	hold       holdState
+}
+
+// NewReentrantRedisLock 创建一个可重入的 Redis 分布式锁
+func NewReentrantRedisLock(client *redis.Client, key, value string, expiration time.Duration, ops ...Option) *reentrantLock {
+	return &reentrantLock{
+		client:     client,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		opt:        newOptions(ops),
+	}
+}
+
+// TryLock 尝试获取锁，已经持有时持有次数加一
+func (rl *reentrantLock) TryLock(ctx context.Context) error {
+	return rl.tryLock(ctx)
+}
+
+// WaitLock 获取锁，如果获取失败会重试直到超时
+func (rl *reentrantLock) WaitLock(ctx context.Context, timeout time.Duration) error {
+	return pollLock(ctx, timeout, rl.tryLock)
+}
+
+func (rl *reentrantLock) tryLock(ctx context.Context) error {
+	// 锁不存在或者由自己持有时，持有次数加一并重置过期时间
+	script := `
+	if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		local n = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return n
+	end
+	return 0
+	`
+
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, []string{rl.key}, rl.value, millis).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrLockAcquireFailed
+	}
+
+	// 第一次获取时启动看门狗
+	if n == 1 && rl.opt.watchdogRatio > 0 {
+		rl.hold.start(context.WithoutCancel(ctx), rl.expiration, rl.opt.watchdogRatio, rl.Refresh)
+	}
+	return nil
+}
+
+// Unlock 持有次数减一，减到 0 时释放锁
+func (rl *reentrantLock) Unlock(ctx context.Context) error {
+	script := `
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+		return -1
+	end
+	local n = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+	if n > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return n
+	end
+	redis.call("DEL", KEYS[1])
+	return 0
+	`
+
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, []string{rl.key}, rl.value, millis).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n < 0 {
+		rl.hold.stop()
+		return ErrLockReleaseFailed
+	}
+	if n == 0 {
+		rl.hold.stop()
+	}
+	return nil
+}
+
+// Refresh 刷新锁的过期时间
+func (rl *reentrantLock) Refresh(ctx context.Context) error {
+	script := `
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	else
+		return 0
+	end
+	`
+
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, []string{rl.key}, rl.value, millis).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// HoldCount 返回当前持有者的持有次数，未持有时为 0
+func (rl *reentrantLock) HoldCount(ctx context.Context) (int64, error) {
+	n, err := rl.client.HGet(ctx, rl.key, rl.value).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
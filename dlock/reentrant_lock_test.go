@@ -0,0 +1,39 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReentrantLock(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	owner := NewReentrantRedisLock(client, "reentrant", "client-1", time.Minute)
+	other := NewReentrantRedisLock(client, "reentrant", "client-2", time.Minute)
+
+	assert.NoError(t, owner.TryLock(ctx))
+	assert.NoError(t, owner.TryLock(ctx))
+	assert.NoError(t, owner.WaitLock(ctx, time.Second))
+	n, err := owner.HoldCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	assert.ErrorIs(t, other.TryLock(ctx), ErrLockAcquireFailed)
+	assert.ErrorIs(t, other.Unlock(ctx), ErrLockReleaseFailed)
+
+	assert.NoError(t, owner.Unlock(ctx))
+	assert.NoError(t, owner.Unlock(ctx))
+	assert.True(t, s.Exists("reentrant"))
+	assert.ErrorIs(t, other.TryLock(ctx), ErrLockAcquireFailed)
+
+	assert.NoError(t, owner.Unlock(ctx))
+	assert.False(t, s.Exists("reentrant"))
+	assert.ErrorIs(t, owner.Unlock(ctx), ErrLockReleaseFailed)
+	assert.ErrorIs(t, owner.Refresh(ctx), ErrLockReleaseFailed)
+
+	assert.NoError(t, other.TryLock(ctx))
+	assert.NoError(t, other.Refresh(ctx))
+}
@@ -0,0 +1,221 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ IRWLock = &redisRWLock{}
+)
+
+// rwWriterWaitLease 是等待中的写者的租期，写者每次重试都会续期，崩溃的写者过期后不再阻塞读者
+const rwWriterWaitLease = 2 * time.Second
+
+// IRWLock 是读写锁，多个读者可以同时持有读锁，写锁是独占的
+type IRWLock interface {
+	// RLock 获取读锁，如果获取失败会重试直到超时，timeout 为 0 时只尝试一次
+	RLock(ctx context.Context, timeout time.Duration) error
+	// RUnlock 释放读锁
+	RUnlock(ctx context.Context) error
+	// Lock 获取写锁，如果获取失败会重试直到超时，timeout 为 0 时只尝试一次
+	Lock(ctx context.Context, timeout time.Duration) error
+	// Unlock 释放写锁
+	Unlock(ctx context.Context) error
+	// Refresh 刷新当前持有的读锁或写锁的过期时间
+	Refresh(ctx context.Context) error
+}
+
+// redisRWLock 是基于 Redis 的读写锁
+// 读者保存在 <key>:readers 有序集合中，score 是每个读者按 Redis 时钟计算的过期时间，崩溃的读者过期后自动移除
+// 写者保存在 <key>:writer 中，等待中的写者登记在 <key>:writers 中，有写者等待时新的读者不能加锁，避免写者饿死
+type redisRWLock struct {
+	client     *redis.Client
+	key        string
+	value      string
+	expiration time.Duration
+	opt        *options
+	hold       holdState
+}
+
+// NewRedisRWLock 创建一个基于 Redis 的读写锁，value 需要在所有持有者之间唯一
+func NewRedisRWLock(client *redis.Client, key, value string, expiration time.Duration, ops ...Option) *redisRWLock {
+	return &redisRWLock{
+		client:     client,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		opt:        newOptions(ops),
+	}
+}
+
+func (rl *redisRWLock) readersKey() string { return rl.key + ":readers" }
+func (rl *redisRWLock) writerKey() string  { return rl.key + ":writer" }
+func (rl *redisRWLock) waitingKey() string { return rl.key + ":writers" }
+
+// RLock 获取读锁，没有写者持有或等待时才能获取
+func (rl *redisRWLock) RLock(ctx context.Context, timeout time.Duration) error {
+	if err := pollLock(ctx, timeout, rl.tryRLock); err != nil {
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+func (rl *redisRWLock) tryRLock(ctx context.Context) error {
+	// KEYS: 读者, 写者, 等待的写者
+	// ARGV: value, 锁过期毫秒
+	script := luaNow + `
+	local ttl = tonumber(ARGV[2])
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+	redis.call("ZREMRANGEBYSCORE", KEYS[3], "-inf", now)
+	if redis.call("EXISTS", KEYS[2]) == 1 or redis.call("ZCARD", KEYS[3]) > 0 then
+		return 0
+	end
+	redis.call("ZADD", KEYS[1], now + ttl, ARGV[1])
+	if redis.call("PTTL", KEYS[1]) < ttl then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	return 1
+	`
+
+	keys := []string{rl.readersKey(), rl.writerKey(), rl.waitingKey()}
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, keys, rl.value, millis).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockAcquireFailed
+	}
+
+	return nil
+}
+
+// RUnlock 释放读锁
+func (rl *redisRWLock) RUnlock(ctx context.Context) error {
+	rl.hold.stop()
+
+	n, err := rl.client.ZRem(ctx, rl.readersKey(), rl.value).Result()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// Lock 获取写锁，等待期间会登记为等待中的写者，新的读者不能再加锁
+func (rl *redisRWLock) Lock(ctx context.Context, timeout time.Duration) error {
+	err := pollLock(ctx, timeout, rl.tryLock)
+	if err != nil {
+		// 放弃等待，不再阻塞读者
+		cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		rl.client.ZRem(cctx, rl.waitingKey(), rl.value)
+		cancel()
+		return err
+	}
+	rl.startWatchdog(ctx)
+	return nil
+}
+
+func (rl *redisRWLock) tryLock(ctx context.Context) error {
+	// KEYS: 读者, 写者, 等待的写者
+	// ARGV: value, 锁过期毫秒, 等待租期毫秒
+	script := luaNow + `
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+	redis.call("ZREMRANGEBYSCORE", KEYS[3], "-inf", now)
+	if redis.call("EXISTS", KEYS[2]) == 0 and redis.call("ZCARD", KEYS[1]) == 0 then
+		redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[2])
+		redis.call("ZREM", KEYS[3], ARGV[1])
+		return 1
+	end
+	redis.call("ZADD", KEYS[3], now + tonumber(ARGV[3]), ARGV[1])
+	redis.call("PEXPIRE", KEYS[3], tonumber(ARGV[3]) * 2)
+	return 0
+	`
+
+	keys := []string{rl.readersKey(), rl.writerKey(), rl.waitingKey()}
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, keys, rl.value, millis, rwWriterWaitLease.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockAcquireFailed
+	}
+
+	return nil
+}
+
+// Unlock 释放写锁
+func (rl *redisRWLock) Unlock(ctx context.Context) error {
+	rl.hold.stop()
+
+	script := `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	else
+		return 0
+	end
+	`
+
+	n, err := rl.client.Eval(ctx, script, []string{rl.writerKey()}, rl.value).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// Refresh 刷新当前持有的读锁或写锁的过期时间
+func (rl *redisRWLock) Refresh(ctx context.Context) error {
+	// KEYS: 读者, 写者
+	// ARGV: value, 锁过期毫秒
+	script := luaNow + `
+	local ttl = tonumber(ARGV[2])
+	if redis.call("GET", KEYS[2]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[2], ttl)
+	end
+	local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+	if score == false or tonumber(score) <= now then
+		return 0
+	end
+	redis.call("ZADD", KEYS[1], "XX", now + ttl, ARGV[1])
+	if redis.call("PTTL", KEYS[1]) < ttl then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	return 1
+	`
+
+	keys := []string{rl.readersKey(), rl.writerKey()}
+	millis := int64(rl.expiration / time.Millisecond)
+	n, err := rl.client.Eval(ctx, script, keys, rl.value, millis).Int64()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	rl.hold.extend(rl.expiration)
+	return nil
+}
+
+func (rl *redisRWLock) startWatchdog(ctx context.Context) {
+	if rl.opt.watchdogRatio > 0 {
+		rl.hold.start(context.WithoutCancel(ctx), rl.expiration, rl.opt.watchdogRatio, rl.Refresh)
+	}
+}
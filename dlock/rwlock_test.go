@@ -0,0 +1,79 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRWLock(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	reader1 := NewRedisRWLock(client, "rw", "reader-1", time.Second)
+	reader2 := NewRedisRWLock(client, "rw", "reader-2", time.Second)
+	writer := NewRedisRWLock(client, "rw", "writer", time.Second)
+
+	// readers share the lock, the writer waits for both
+	assert.NoError(t, reader1.RLock(ctx, 0))
+	assert.NoError(t, reader2.RLock(ctx, 0))
+	assert.ErrorIs(t, writer.Lock(ctx, 0), ErrLockAcquireFailed)
+	assert.NoError(t, reader1.Refresh(ctx))
+
+	done := make(chan error, 1)
+	go func() { done <- writer.Lock(ctx, 2*time.Second) }()
+
+	// a waiting writer blocks new readers
+	assert.Eventually(t, func() bool {
+		return reader1.RLock(ctx, 0) == ErrLockAcquireFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, reader1.RUnlock(ctx))
+	assert.NoError(t, reader2.RUnlock(ctx))
+	assert.NoError(t, <-done)
+
+	assert.ErrorIs(t, reader1.RLock(ctx, 0), ErrLockAcquireFailed)
+	assert.NoError(t, writer.Refresh(ctx))
+	assert.NoError(t, writer.Unlock(ctx))
+	assert.NoError(t, reader1.RLock(ctx, 0))
+	assert.ErrorIs(t, writer.Unlock(ctx), ErrLockReleaseFailed)
+	assert.NoError(t, reader1.RUnlock(ctx))
+	assert.ErrorIs(t, reader1.RUnlock(ctx), ErrLockReleaseFailed)
+}
+
+func TestRWLockReaderLease(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	reader := NewRedisRWLock(client, "rw", "reader", 100*time.Millisecond)
+	writer := NewRedisRWLock(client, "rw", "writer", time.Second)
+
+	// a crashed reader never unlocks, its lease expires by itself
+	assert.NoError(t, reader.RLock(ctx, 0))
+	assert.NoError(t, writer.Lock(ctx, time.Second))
+	assert.ErrorIs(t, reader.Refresh(ctx), ErrLockReleaseFailed)
+	assert.NoError(t, writer.Unlock(ctx))
+
+	// a writer that gave up waiting no longer blocks readers
+	assert.NoError(t, reader.RLock(ctx, 0))
+	assert.ErrorIs(t, writer.Lock(ctx, 0), ErrLockAcquireFailed)
+	assert.NoError(t, reader.RUnlock(ctx))
+	assert.NoError(t, reader.RLock(ctx, 0))
+	assert.NoError(t, reader.RUnlock(ctx))
+}
+
+func TestRWLockUsesRedisClock(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	reader := NewRedisRWLock(client, "rw", "reader", time.Second)
+	writer := NewRedisRWLock(client, "rw", "writer", time.Second)
+
+	// reader leases are judged by the Redis clock, a writer can not purge a live reader
+	assert.NoError(t, reader.RLock(ctx, 0))
+	assert.ErrorIs(t, writer.Lock(ctx, 0), ErrLockAcquireFailed)
+
+	// the lease runs out on the Redis clock
+	s.SetTime(time.Now().Add(2 * time.Second))
+	assert.ErrorIs(t, reader.Refresh(ctx), ErrLockReleaseFailed)
+	assert.NoError(t, writer.Lock(ctx, 0))
+	assert.NoError(t, writer.Unlock(ctx))
+}
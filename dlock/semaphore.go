@@ -0,0 +1,203 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSemaphoreHeld 表示同一个 Semaphore 在释放之前再次 Acquire
+var ErrSemaphoreHeld = errors.New("semaphore permits already held")
+
+// Semaphore 是基于 Redis 的分布式信号量，限制所有副本上同时运行的任务数
+// 许可保存在 key 对应的有序集合中，score 是按 Redis 时钟计算的许可过期时间，崩溃的持有者过期后自动归还许可
+type Semaphore struct {
+	client     *redis.Client
+	key        string
+	value      string
+	limit      int
+	expiration time.Duration
+	opt        *options
+	hold       holdState
+
+	mu      sync.Mutex
+	members []string
+}
+
+// NewSemaphore 创建一个最多 limit 个许可的信号量，value 需要在所有持有者之间唯一
+// 一个 Semaphore 同时只持有一批许可，需要并发获取时每个任务创建自己的 Semaphore
+func NewSemaphore(client *redis.Client, key, value string, limit int, expiration time.Duration, ops ...Option) *Semaphore {
+	return &Semaphore{
+		client:     client,
+		key:        key,
+		value:      value,
+		limit:      limit,
+		expiration: expiration,
+		opt:        newOptions(ops),
+	}
+}
+
+// TryAcquire 尝试获取 n 个许可，立即返回结果
+func (s *Semaphore) TryAcquire(ctx context.Context, n int) error {
+	if err := s.tryAcquire(ctx, n); err != nil {
+		return err
+	}
+	s.startWatchdog(ctx)
+	return nil
+}
+
+// Acquire 获取 n 个许可，许可不足时重试直到获取成功或 ctx 结束
+func (s *Semaphore) Acquire(ctx context.Context, n int) error {
+	for {
+		err := s.tryAcquire(ctx, n)
+		if err == nil {
+			s.startWatchdog(ctx)
+			return nil
+		}
+
+		if err != ErrLockAcquireFailed {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(2 * pollInterval)):
+		}
+	}
+}
+
+func (s *Semaphore) tryAcquire(ctx context.Context, n int) error {
+	if n <= 0 || n > s.limit {
+		return fmt.Errorf("invalid permits %d, limit %d", n, s.limit)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.members) > 0 {
+		return ErrSemaphoreHeld
+	}
+
+	// KEYS: 许可
+	// ARGV: 上限, 过期毫秒, 许可...
+	script := luaNow + `
+	local ttl = tonumber(ARGV[2])
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+	if redis.call("ZCARD", KEYS[1]) + #ARGV - 2 > tonumber(ARGV[1]) then
+		return 0
+	end
+	for i = 3, #ARGV do
+		redis.call("ZADD", KEYS[1], now + ttl, ARGV[i])
+	end
+	if redis.call("PTTL", KEYS[1]) < ttl then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	return 1
+	`
+
+	members := s.permits(n)
+	args := []any{s.limit, int64(s.expiration / time.Millisecond)}
+	for _, m := range members {
+		args = append(args, m)
+	}
+	ok, err := s.client.Eval(ctx, script, []string{s.key}, args...).Int64()
+	if err != nil {
+		return err
+	}
+
+	if ok != 1 {
+		return ErrLockAcquireFailed
+	}
+
+	s.members = members
+	return nil
+}
+
+// permits 返回 n 个许可在有序集合中的成员
+func (s *Semaphore) permits(n int) []string {
+	members := make([]string, n)
+	for i := range members {
+		members[i] = s.value + ":" + strconv.Itoa(i)
+	}
+	return members
+}
+
+func (s *Semaphore) startWatchdog(ctx context.Context) {
+	if s.opt.watchdogRatio > 0 {
+		s.hold.start(context.WithoutCancel(ctx), s.expiration, s.opt.watchdogRatio, s.Refresh)
+	}
+}
+
+// Release 归还持有的许可，许可已经过期时返回 ErrLockReleaseFailed
+func (s *Semaphore) Release(ctx context.Context) error {
+	s.hold.stop()
+
+	s.mu.Lock()
+	members := s.members
+	s.members = nil
+	s.mu.Unlock()
+	if len(members) == 0 {
+		return ErrLockReleaseFailed
+	}
+
+	n, err := s.client.ZRem(ctx, s.key, members).Result()
+	if err != nil {
+		return err
+	}
+
+	if n != int64(len(members)) {
+		return ErrLockReleaseFailed
+	}
+
+	return nil
+}
+
+// Refresh 续期持有的许可，任何一个许可已经过期时返回 ErrLockReleaseFailed
+func (s *Semaphore) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	members := s.members
+	s.mu.Unlock()
+	if len(members) == 0 {
+		return ErrLockReleaseFailed
+	}
+
+	// KEYS: 许可
+	// ARGV: 过期毫秒, 许可...
+	script := luaNow + `
+	local ttl = tonumber(ARGV[1])
+	for i = 2, #ARGV do
+		local score = redis.call("ZSCORE", KEYS[1], ARGV[i])
+		if score == false or tonumber(score) <= now then
+			return 0
+		end
+	end
+	for i = 2, #ARGV do
+		redis.call("ZADD", KEYS[1], "XX", now + ttl, ARGV[i])
+	end
+	if redis.call("PTTL", KEYS[1]) < ttl then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	return 1
+	`
+
+	args := []any{int64(s.expiration / time.Millisecond)}
+	for _, m := range members {
+		args = append(args, m)
+	}
+	ok, err := s.client.Eval(ctx, script, []string{s.key}, args...).Int64()
+	if err != nil {
+		return err
+	}
+
+	if ok != 1 {
+		return ErrLockReleaseFailed
+	}
+
+	s.hold.extend(s.expiration)
+	return nil
+}
@@ -0,0 +1,73 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	sem1 := NewSemaphore(client, "sem", "job-1", 3, time.Second)
+	sem2 := NewSemaphore(client, "sem", "job-2", 3, time.Second)
+	sem3 := NewSemaphore(client, "sem", "job-3", 3, time.Second)
+
+	assert.Error(t, sem1.TryAcquire(ctx, 4))
+	assert.NoError(t, sem1.TryAcquire(ctx, 2))
+	assert.ErrorIs(t, sem1.TryAcquire(ctx, 1), ErrSemaphoreHeld)
+	assert.NoError(t, sem2.TryAcquire(ctx, 1))
+	assert.ErrorIs(t, sem3.TryAcquire(ctx, 1), ErrLockAcquireFailed)
+	assert.NoError(t, sem1.Refresh(ctx))
+
+	done := make(chan error, 1)
+	go func() { done <- sem3.Acquire(ctx, 2) }()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, sem1.Release(ctx))
+	assert.NoError(t, <-done)
+
+	assert.NoError(t, sem2.Release(ctx))
+	assert.NoError(t, sem3.Release(ctx))
+	assert.ErrorIs(t, sem3.Release(ctx), ErrLockReleaseFailed)
+
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, sem1.Acquire(tctx, 3))
+	assert.ErrorIs(t, sem2.Acquire(tctx, 1), context.DeadlineExceeded)
+	assert.NoError(t, sem1.Release(ctx))
+}
+
+func TestSemaphoreLease(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	crashed := NewSemaphore(client, "sem", "crashed", 1, 100*time.Millisecond)
+	sem := NewSemaphore(client, "sem", "job", 1, time.Second)
+
+	// a crashed holder never releases, its permit expires by itself
+	assert.NoError(t, crashed.TryAcquire(ctx, 1))
+	assert.ErrorIs(t, sem.TryAcquire(ctx, 1), ErrLockAcquireFailed)
+	time.Sleep(150 * time.Millisecond)
+	assert.NoError(t, sem.TryAcquire(ctx, 1))
+	assert.ErrorIs(t, crashed.Refresh(ctx), ErrLockReleaseFailed)
+	assert.ErrorIs(t, crashed.Release(ctx), ErrLockReleaseFailed)
+	assert.NoError(t, sem.Release(ctx))
+}
+
+func TestSemaphoreUsesRedisClock(t *testing.T) {
+	s, client := newTestRedis(t)
+	ctx := context.Background()
+	holder := NewSemaphore(client, "sem", "holder", 1, time.Second)
+	other := NewSemaphore(client, "sem", "other", 1, time.Second)
+
+	// permits are judged by the Redis clock, another replica can not evict a live permit
+	assert.NoError(t, holder.TryAcquire(ctx, 1))
+	assert.ErrorIs(t, other.TryAcquire(ctx, 1), ErrLockAcquireFailed)
+
+	// the permit runs out on the Redis clock
+	s.SetTime(time.Now().Add(2 * time.Second))
+	assert.ErrorIs(t, holder.Refresh(ctx), ErrLockReleaseFailed)
+	assert.NoError(t, other.TryAcquire(ctx, 1))
+	assert.NoError(t, other.Release(ctx))
+}
@@ -0,0 +1,38 @@
+package dlock
+
+import (
+	"context"
+	"time"
+)
+
+// pollInterval 是轮询获取锁的间隔
+const pollInterval = 50 * time.Millisecond
+
+// pollLock 反复调用 try 获取锁直到成功、超时或 ctx 结束
+func pollLock(ctx context.Context, timeout time.Duration, try func(context.Context) error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		// 尝试获取锁
+		err := try(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if err != ErrLockAcquireFailed {
+			return err
+		}
+
+		// 检查是否超时
+		if time.Now().After(deadline) {
+			return ErrLockAcquireFailed
+		}
+
+		// 等待一段时间后重试
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+			// 继续尝试
+		}
+	}
+}
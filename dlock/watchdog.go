@@ -0,0 +1,88 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// holdState 管理一次持有锁期间的上下文和续期
+type holdState struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+}
+
+// start 创建持有锁期间的上下文，Unlock 或确认锁丢失时取消，原因可以通过 context.Cause 获取
+// ratio 大于 0 时启动看门狗续期，否则上下文在锁过期时取消
+func (h *holdState) start(ctx context.Context, expiration time.Duration, ratio float64, refresh func(context.Context) error) context.Context {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+
+	h.mu.Lock()
+	h.release()
+	h.cancel = cancel
+	if ratio > 0 {
+		go watchdog(lockCtx, cancel, expiration, time.Duration(float64(expiration)*ratio), refresh)
+	} else {
+		h.timer = time.AfterFunc(expiration, func() { cancel(ErrLockLost) })
+	}
+	h.mu.Unlock()
+	return lockCtx
+}
+
+// extend 手动 Refresh 成功后顺延上下文的过期时间
+func (h *holdState) extend(expiration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timer != nil {
+		h.timer.Reset(expiration)
+	}
+}
+
+// stop 结束续期并取消持有锁期间的上下文
+func (h *holdState) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.release()
+}
+
+func (h *holdState) release() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if h.cancel != nil {
+		h.cancel(nil)
+		h.cancel = nil
+	}
+}
+
+// watchdog 定期刷新锁，确认锁已丢失时以 ErrLockLost 取消上下文
+// 网络错误会在下个周期重试，直到距离上次成功续期已经超过过期时间
+func watchdog(ctx context.Context, cancel context.CancelCauseFunc, expiration, interval time.Duration, refresh func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastOK := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rctx, rcancel := context.WithTimeout(ctx, interval)
+			err := refresh(rctx)
+			rcancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				lastOK = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrLockReleaseFailed) || time.Since(lastOK)+interval >= expiration {
+				cancel(ErrLockLost)
+				return
+			}
+		}
+	}
+}
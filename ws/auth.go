@@ -0,0 +1,104 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConnInfo 是连接建立时的元数据，通过 IWriter.Context 传给每个 HandlerFunc
+type ConnInfo struct {
+	ID string
+	// Principal 是 Authenticate 返回的身份，没有设置 Authenticate 时为 nil
+	Principal   any
+	RemoteAddr  string
+	Header      http.Header
+	URL         *url.URL
+	ConnectedAt time.Time
+}
+
+// IPrincipal 是带用户 ID 的身份，Authenticate 返回它或者 string 时可以按用户查找连接
+type IPrincipal interface {
+	UserID() string
+}
+
+type connInfoKey struct{}
+
+// ConnInfoFrom 从连接上下文中获取连接的元数据
+func ConnInfoFrom(ctx context.Context) (*ConnInfo, bool) {
+	info, ok := ctx.Value(connInfoKey{}).(*ConnInfo)
+	return info, ok
+}
+
+// PrincipalFrom 从连接上下文中获取 Authenticate 返回的身份
+func PrincipalFrom(ctx context.Context) any {
+	if info, ok := ConnInfoFrom(ctx); ok {
+		return info.Principal
+	}
+	return nil
+}
+
+// WithAllowedOrigins 只允许来自这些 Origin 的连接，"*" 表示允许所有来源
+// 没有 Origin 头的请求（非浏览器客户端）总是允许
+func WithAllowedOrigins(origins ...string) ServerOptions {
+	return func(s *Server) error {
+		allowed := make(map[string]struct{}, len(origins))
+		for _, o := range origins {
+			allowed[strings.ToLower(strings.TrimSuffix(o, "/"))] = struct{}{}
+		}
+		s.upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			if _, ok := allowed["*"]; ok {
+				return true
+			}
+			_, ok := allowed[strings.ToLower(origin)]
+			return ok
+		}
+		return nil
+	}
+}
+
+// WithAuthenticate 在升级连接之前认证请求，返回错误时以 401 拒绝，不会升级为 WebSocket
+// 返回的 principal 可以通过 PrincipalFrom(writer.Context()) 获取
+func WithAuthenticate(authenticate func(r *http.Request) (principal any, err error)) ServerOptions {
+	return func(s *Server) error {
+		s.authenticate = authenticate
+		return nil
+	}
+}
+
+func userIDOf(principal any) string {
+	switch p := principal.(type) {
+	case string:
+		return p
+	case IPrincipal:
+		return p.UserID()
+	default:
+		return ""
+	}
+}
+
+// ConnectionsByUser 返回用户的所有连接 ID
+func (h *Server) ConnectionsByUser(userID string) []string {
+	h.userMu.RLock()
+	defer h.userMu.RUnlock()
+	res := make([]string, 0, len(h.users.members[userID]))
+	for connID := range h.users.members[userID] {
+		res = append(res, connID)
+	}
+	return res
+}
+
+// SendToUser 向用户的所有连接发送消息
+func (h *Server) SendToUser(userID string, message any) {
+	for _, connID := range h.ConnectionsByUser(userID) {
+		if err := h.SendTo(connID, message); err != nil {
+			h.log.WithError(err).WithField("user", userID).Error("Send to user failed")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authByQuery accepts ?user=<id> and rejects requests without it
+func authByQuery(r *http.Request) (any, error) {
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		return nil, errors.New("missing user")
+	}
+	return user, nil
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	url := newTestServer(t, newServer(t, WithAllowedOrigins("https://app.example.com/")))
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://APP.example.com"}})
+	require.NoError(t, err)
+	conn.Close()
+
+	// clients without an Origin header are not browsers
+	dial(t, url)
+}
+
+func TestAuthenticateRejectsBeforeUpgrade(t *testing.T) {
+	s := newServer(t, WithAuthenticate(authByQuery))
+	url := newTestServer(t, s)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	count := 0
+	s.connections.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 0, count)
+}
+
+func TestPrincipalAndConnInfo(t *testing.T) {
+	s := newServer(t, WithAuthenticate(authByQuery))
+	s.RegisterHandler("me", func(w IWriter, _ json.RawMessage, connID string) error {
+		info, ok := ConnInfoFrom(w.Context())
+		if !ok {
+			return errors.New("no conn info")
+		}
+		return w.Reply(map[string]any{
+			"principal": PrincipalFrom(w.Context()),
+			"id_match":  info.ID == connID,
+			"user":      info.URL.Query().Get("user"),
+		})
+	})
+	conn := dial(t, newTestServer(t, s)+"?user=alice")
+
+	assert.JSONEq(t, `{"principal":"alice","id_match":true,"user":"alice"}`, string(call(t, conn, "me", "1", nil).Data))
+}
+
+func TestConnectionsByUserCleanup(t *testing.T) {
+	s := newServer(t, WithAuthenticate(authByQuery))
+	url := newTestServer(t, s)
+	first := dial(t, url+"?user=alice")
+	second := dial(t, url+"?user=alice")
+	firstID := connID(t, first)
+	secondID := connID(t, second)
+	assert.ElementsMatch(t, []string{firstID, secondID}, s.ConnectionsByUser("alice"))
+
+	s.SendToUser("alice", ToMessage{Type: "notice"})
+	assert.Equal(t, "notice", readMessage(t, first).Type)
+	assert.Equal(t, "notice", readMessage(t, second).Type)
+
+	first.Close()
+	assert.Eventually(t, func() bool {
+		ids := s.ConnectionsByUser("alice")
+		return len(ids) == 1 && ids[0] == secondID
+	}, 2*time.Second, 10*time.Millisecond)
+
+	second.Close()
+	assert.Eventually(t, func() bool {
+		s.userMu.RLock()
+		defer s.userMu.RUnlock()
+		return len(s.users.members) == 0 && len(s.users.joined) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestContextDoneAfterDisconnect(t *testing.T) {
+	s := newServer(t)
+	done := make(chan struct{})
+	s.RegisterHandler("watch", func(w IWriter, _ json.RawMessage, _ string) error {
+		// the handler returns at once, work it starts can watch the connection
+		go func() {
+			<-w.Context().Done()
+			close(done)
+		}()
+		return nil
+	})
+	conn := dial(t, newTestServer(t, s))
+	call(t, conn, "watch", "1", nil)
+
+	select {
+	case <-done:
+		t.Fatal("context cancelled while the connection is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	conn.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled after disconnect")
+	}
+}
@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BrokerMessage 是节点之间转发的广播或房间消息
+type BrokerMessage struct {
+	// NodeID 是发布消息的节点，节点收到自己发布的消息时直接丢弃，本地连接已经在发布时投递过
+	NodeID string `json:"node_id"`
+	// Room 为空时表示广播
+	Room    string          `json:"room,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// IBroker 把 Broadcast 和 PublishTo 的消息分发到所有节点
+type IBroker interface {
+	// Publish 发布消息到所有节点，包括自己
+	Publish(ctx context.Context, msg *BrokerMessage) error
+	// Subscribe 开始接收其他节点的消息，handler 在接收协程中调用
+	Subscribe(ctx context.Context, handler func(msg *BrokerMessage)) error
+	// Close 停止接收消息
+	Close() error
+}
+
+// WithBroker 设置多节点之间的消息分发
+func WithBroker(broker IBroker) ServerOptions {
+	return func(s *Server) error {
+		s.broker = broker
+		return nil
+	}
+}
+
+// WithNodeID 设置当前节点的 ID，默认随机生成
+func WithNodeID(nodeID string) ServerOptions {
+	return func(s *Server) error {
+		s.nodeID = nodeID
+		return nil
+	}
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startBroker 订阅其他节点的消息并投递给本地连接
+func (h *Server) startBroker() error {
+	if h.broker == nil {
+		return nil
+	}
+	return h.broker.Subscribe(context.Background(), func(msg *BrokerMessage) {
+		if msg.NodeID == h.nodeID {
+			return
+		}
+		if msg.Room == "" {
+			h.broadcastLocal(msg.Payload)
+		} else {
+			h.publishLocal(msg.Room, msg.Payload)
+		}
+	})
+}
+
+// publishRemote 把消息发布给其他节点
+func (h *Server) publishRemote(room string, message any) {
+	if h.broker == nil {
+		return
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		h.log.WithError(err).Error("Marshal broker message failed")
+		return
+	}
+	msg := &BrokerMessage{NodeID: h.nodeID, Room: room, Payload: payload}
+	if err := h.broker.Publish(context.Background(), msg); err != nil {
+		h.log.WithError(err).WithField("room", room).Error("Publish to broker failed")
+	}
+}
+
+// Close 停止接收其他节点的消息
+func (h *Server) Close() error {
+	if h.broker == nil {
+		return nil
+	}
+	return h.broker.Close()
+}
+
+// RedisBroker 基于 Redis pub/sub 的 IBroker 实现，所有节点使用同一个 channel
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+	sub     *redis.PubSub
+}
+
+// NewRedisBroker 创建一个基于 Redis pub/sub 的 IBroker
+func NewRedisBroker(client *redis.Client, channel string) *RedisBroker {
+	return &RedisBroker{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, msg *BrokerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, handler func(msg *BrokerMessage)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return fmt.Errorf("subscribe %s fail %w", b.channel, err)
+	}
+	b.sub = sub
+
+	go func() {
+		for m := range sub.Channel() {
+			var msg BrokerMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				continue
+			}
+			handler(&msg)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBroker) Close() error {
+	if b.sub == nil {
+		return nil
+	}
+	return b.sub.Close()
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBrokerTwoNodes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newNode := func(nodeID string) *Server {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return newServer(t, WithBroker(NewRedisBroker(client, "ws")), WithNodeID(nodeID))
+	}
+	nodeA := newNode("node-a")
+	nodeB := newNode("node-b")
+	connA := dial(t, newTestServer(t, nodeA))
+	connB := dial(t, newTestServer(t, nodeB))
+	idB := connID(t, connB)
+	connID(t, connA)
+	require.NoError(t, nodeB.Join(idB, "room-1"))
+
+	nodeA.Broadcast(ToMessage{Type: "broadcast"})
+	nodeA.PublishTo("room-1", ToMessage{Type: "room"})
+
+	// the other node delivers both to its local connections
+	assert.Equal(t, "broadcast", readMessage(t, connB).Type)
+	assert.Equal(t, "room", readMessage(t, connB).Type)
+
+	// the publishing node delivered locally once and skipped its own broker message,
+	// the marker travels the channel after node-a's messages so a duplicate would arrive first
+	nodeB.Broadcast(ToMessage{Type: "marker"})
+	assert.Equal(t, "broadcast", readMessage(t, connA).Type)
+	assert.Equal(t, "marker", readMessage(t, connA).Type)
+	assert.Equal(t, "marker", readMessage(t, connB).Type)
+}
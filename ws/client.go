@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -26,7 +27,10 @@ var (
 )
 
 type Client struct {
-	conn        *websocket.Conn
+	conn *websocket.Conn
+	// 出站队列，所有写入都经过它
+	out         *outConn
+	sendQueue   SendQueueConfig
 	url         string
 	mu          sync.RWMutex
 	isConnected bool
@@ -45,10 +49,16 @@ type Client struct {
 
 	handler map[string]ClientHandlerFunc
 
+	// 等待回复的 Call
+	nextID    atomic.Uint64
+	pendingMu sync.Mutex
+	pending   map[string]chan FromMessage
+
 	// 控制通道
-	ctx    context.Context
-	cancel context.CancelFunc
-	done   chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	doneOnce sync.Once
 
 	log *logrus.Entry
 }
@@ -64,6 +74,9 @@ func NewClient(_url string, ops ...ClientOptions) (*Client, error) {
 		cancel:        cancel,
 		done:          make(chan struct{}),
 		pool:          pool.NewPool(10),
+		sendQueue:     defaultSendQueueConfig,
+		handler:       make(map[string]ClientHandlerFunc),
+		pending:       make(map[string]chan FromMessage),
 		log:           logrus.WithField("module", "ws"),
 	}
 	for _, opt := range ops {
@@ -92,7 +105,7 @@ func (c *Client) Disconnect() error {
 
 	if c.conn != nil {
 		c.isConnected = false
-		err := c.conn.Close()
+		err := c.out.close()
 		c.conn = nil
 		if err != nil {
 			return err
@@ -100,12 +113,7 @@ func (c *Client) Disconnect() error {
 	}
 
 	// 安全关闭 done（避免重复关闭 panic）
-	select {
-	case <-c.done:
-		// 已关闭
-	default:
-		close(c.done)
-	}
+	c.closeDone()
 
 	return nil
 }
@@ -119,14 +127,16 @@ func (c *Client) IsConnected() bool {
 
 // 发送消息
 func (c *Client) SendMessage(message any) error {
+	// 入队在 PolicyBlock 时可能阻塞，不能持有锁，否则会卡住断开和重连
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	isConnected, noConn, out := c.isConnected, c.conn == nil, c.out
+	c.mu.RUnlock()
 
-	if !c.isConnected || c.conn == nil {
-		return fmt.Errorf("websocket not connected, isConnected: %v, conn is empty: %v", c.isConnected, c.conn == nil)
+	if !isConnected || noConn {
+		return fmt.Errorf("websocket not connected, isConnected: %v, conn is empty: %v", isConnected, noConn)
 	}
 
-	err := c.conn.WriteJSON(message)
+	err := out.WriteJSON(message)
 	if err != nil {
 		return fmt.Errorf("send message failed %w", err)
 	}
@@ -146,6 +156,11 @@ func (c *Client) Wait() {
 	<-c.done
 }
 
+// closeDone 关闭 done，Disconnect、读协程和重连协程都可能调用
+func (c *Client) closeDone() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
+
 // 连接到WebSocket服务器
 func (c *Client) connect() error {
 	u, err := url.Parse(c.url)
@@ -162,6 +177,7 @@ func (c *Client) connect() error {
 
 	c.mu.Lock()
 	c.conn = conn
+	c.out = newOutConn(conn, c.sendQueue)
 	c.isConnected = true
 	c.mu.Unlock()
 
@@ -207,7 +223,14 @@ func (c *Client) readMessages() {
 		}
 		c.mu.Lock()
 		c.isConnected = false
+		if c.out != nil {
+			// 停止写协程
+			c.out.close()
+		}
+		// Disconnect 会在锁内关闭重连
+		reconnect := c.connectConfig.reconnectEnabled
 		c.mu.Unlock()
+		c.failPending()
 
 		if c.onDisconnect != nil {
 			c.onDisconnect()
@@ -217,21 +240,13 @@ func (c *Client) readMessages() {
 		select {
 		case <-c.ctx.Done():
 			// 终止：关闭 done
-			select {
-			case <-c.done:
-			default:
-				close(c.done)
-			}
+			c.closeDone()
 		default:
-			if c.connectConfig.reconnectEnabled {
+			if reconnect {
 				go c.reconnectLoop()
 			} else {
 				// 不重连：关闭 done
-				select {
-				case <-c.done:
-				default:
-					close(c.done)
-				}
+				c.closeDone()
 			}
 		}
 	}()
@@ -314,11 +329,7 @@ func (c *Client) reconnectLoop() {
 		if c.connectConfig.maxReconnectRetries > 0 && attempt >= c.connectConfig.maxReconnectRetries {
 			c.log.Warn("达到最大重连次数，停止重连")
 			// 不再重连：关闭 done
-			select {
-			case <-c.done:
-			default:
-				close(c.done)
-			}
+			c.closeDone()
 			return
 		}
 
@@ -350,6 +361,7 @@ func (c *Client) tryReconnect() error {
 
 	c.mu.Lock()
 	c.conn = conn
+	c.out = newOutConn(conn, c.sendQueue)
 	c.isConnected = true
 	c.mu.Unlock()
 
@@ -376,6 +388,9 @@ func (c *Client) handleMessage(message []byte) {
 	}
 
 	// c.log.Debugf("收到消息: %v", baseMsg)
+	if c.resolvePending(baseMsg) {
+		return
+	}
 	msgType := baseMsg.Type
 	switch msgType {
 	case MessageTypePong:
@@ -407,7 +422,7 @@ type clientWriter struct {
 }
 
 func (w *clientWriter) WriteJSON(message any) error {
-	return w.c.conn.WriteJSON(message)
+	return w.c.SendMessage(message)
 }
 
 // 重连配置
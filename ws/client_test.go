@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConnectConfig() *ConnectConfig {
+	return &ConnectConfig{
+		reconnectEnabled:      true,
+		reconnectInitialDelay: 20 * time.Millisecond,
+		reconnectMaxDelay:     100 * time.Millisecond,
+		maxReconnectRetries:   -1,
+		heartbeatInterval:     time.Hour,
+	}
+}
+
+func newClient(t *testing.T, url string, ops ...ClientOptions) *Client {
+	c, err := NewClient(url, append([]ClientOptions{WithConnectConfig(testConnectConfig())}, ops...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func clientConnID(t *testing.T, c *Client) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	data, err := c.Call(ctx, "whoami", nil)
+	require.NoError(t, err)
+	var id string
+	require.NoError(t, json.Unmarshal(data, &id))
+	return id
+}
+
+func TestClientReconnect(t *testing.T) {
+	s := newServer(t)
+	var connects, disconnects atomic.Int32
+	c := newClient(t, newTestServer(t, s),
+		WithOnConnect(func() { connects.Add(1) }),
+		WithOnDisconnect(func() { disconnects.Add(1) }),
+	)
+	first := clientConnID(t, c)
+
+	// the server drops the connection, the client dials again
+	value, ok := s.connections.Load(first)
+	require.True(t, ok)
+	value.(*outConn).close()
+
+	assert.Eventually(t, func() bool {
+		return connects.Load() == 2 && c.IsConnected()
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), disconnects.Load())
+	assert.NotEqual(t, first, clientConnID(t, c))
+}
+
+func TestClientDisconnectStopsReconnect(t *testing.T) {
+	s := newServer(t)
+	var connects atomic.Int32
+	c := newClient(t, newTestServer(t, s), WithOnConnect(func() { connects.Add(1) }))
+	clientConnID(t, c)
+
+	require.NoError(t, c.Disconnect())
+	c.Wait()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), connects.Load())
+	assert.False(t, c.IsConnected())
+	assert.Error(t, c.SendMessage(ToMessage{Type: MessageTypePing}))
+}
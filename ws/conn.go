@@ -0,0 +1,157 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SlowConsumerPolicy 是出站队列满时的处理策略
+type SlowConsumerPolicy int
+
+const (
+	// PolicyDisconnect 队列满时断开连接，客户端会自动重连
+	PolicyDisconnect SlowConsumerPolicy = iota
+	// PolicyDrop 队列满时丢弃新消息
+	PolicyDrop
+	// PolicyBlock 队列满时阻塞等待，最多等待 WriteTimeout
+	PolicyBlock
+)
+
+var (
+	ErrSendQueueFull = fmt.Errorf("send queue is full")
+	ErrConnClosed    = fmt.Errorf("connection closed")
+)
+
+// SendQueueConfig 是每个连接出站队列的配置
+type SendQueueConfig struct {
+	// Size 是队列长度
+	Size int
+	// WriteTimeout 是单条消息的写超时，PolicyBlock 时也是入队的最长等待时间
+	WriteTimeout time.Duration
+	// Policy 是队列满时的处理策略
+	Policy SlowConsumerPolicy
+}
+
+var defaultSendQueueConfig = SendQueueConfig{
+	Size:         256,
+	WriteTimeout: 10 * time.Second,
+	Policy:       PolicyDisconnect,
+}
+
+func (c SendQueueConfig) withDefaults() SendQueueConfig {
+	if c.Size <= 0 {
+		c.Size = defaultSendQueueConfig.Size
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = defaultSendQueueConfig.WriteTimeout
+	}
+	return c
+}
+
+// WithSendQueue 设置服务端每个连接的出站队列
+func WithSendQueue(config SendQueueConfig) ServerOptions {
+	return func(s *Server) error {
+		s.sendQueue = config.withDefaults()
+		return nil
+	}
+}
+
+// WithClientSendQueue 设置客户端的出站队列
+func WithClientSendQueue(config SendQueueConfig) ClientOptions {
+	return func(c *Client) error {
+		c.sendQueue = config.withDefaults()
+		return nil
+	}
+}
+
+// outConn 是带出站队列的连接，gorilla/websocket 只允许一个并发写者，
+// 所有写入都先进入队列，由唯一的写协程按顺序写到连接上
+type outConn struct {
+	conn      *websocket.Conn
+	config    SendQueueConfig
+	send      chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newOutConn(conn *websocket.Conn, config SendQueueConfig) *outConn {
+	c := &outConn{
+		conn:   conn,
+		config: config,
+		send:   make(chan []byte, config.Size),
+		closed: make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// WriteJSON 把消息放入出站队列
+func (c *outConn) WriteJSON(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(data)
+}
+
+func (c *outConn) enqueue(data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrConnClosed
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+	}
+
+	switch c.config.Policy {
+	case PolicyDrop:
+		return ErrSendQueueFull
+	case PolicyBlock:
+		timer := time.NewTimer(c.config.WriteTimeout)
+		defer timer.Stop()
+		select {
+		case c.send <- data:
+			return nil
+		case <-c.closed:
+			return ErrConnClosed
+		case <-timer.C:
+			return ErrSendQueueFull
+		}
+	default:
+		c.close()
+		return ErrSendQueueFull
+	}
+}
+
+func (c *outConn) writeLoop() {
+	for {
+		select {
+		case <-c.closed:
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// close 停止写协程并关闭连接，读协程随后会读到错误并退出
+func (c *outConn) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+	})
+	return err
+}
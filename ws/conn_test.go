@@ -0,0 +1,148 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bigMessage is large enough that a peer which stops reading fills the socket buffers quickly
+var bigMessage = ToMessage{Type: "big", Data: strings.Repeat("x", 1<<20)}
+
+// fillQueue sends to a connection that never reads until the send queue overflows
+func fillQueue(t *testing.T, s *Server, id string) error {
+	for i := 0; i < 200; i++ {
+		if err := s.SendTo(id, bigMessage); err != nil {
+			return err
+		}
+	}
+	t.Fatal("send queue never filled up")
+	return nil
+}
+
+func TestSlowConsumerDrop(t *testing.T) {
+	s := newServer(t, WithSendQueue(SendQueueConfig{Size: 1, WriteTimeout: 5 * time.Second, Policy: PolicyDrop}))
+	conn := dial(t, newTestServer(t, s))
+	id := connID(t, conn)
+
+	assert.ErrorIs(t, fillQueue(t, s, id), ErrSendQueueFull)
+	// the connection stays, only the message is dropped
+	assert.ErrorIs(t, s.SendTo(id, bigMessage), ErrSendQueueFull)
+	_, ok := s.connections.Load(id)
+	assert.True(t, ok)
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	s := newServer(t, WithSendQueue(SendQueueConfig{Size: 1, WriteTimeout: 5 * time.Second, Policy: PolicyDisconnect}))
+	conn := dial(t, newTestServer(t, s))
+	id := connID(t, conn)
+
+	assert.ErrorIs(t, fillQueue(t, s, id), ErrSendQueueFull)
+	// the slow connection is closed and cleaned up
+	assert.Eventually(t, func() bool {
+		return errors.Is(s.SendTo(id, ToMessage{Type: "after"}), ErrConnNotFound)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSlowConsumerBlock(t *testing.T) {
+	s := newServer(t, WithSendQueue(SendQueueConfig{Size: 1, WriteTimeout: 5 * time.Second, Policy: PolicyBlock}))
+	conn := dial(t, newTestServer(t, s))
+	id := connID(t, conn)
+
+	const total = 16
+	var sent atomic.Int32
+	errs := make(chan error, 1)
+	go func() {
+		for i := 0; i < total; i++ {
+			if err := s.SendTo(id, bigMessage); err != nil {
+				errs <- err
+				return
+			}
+			sent.Add(1)
+		}
+		errs <- nil
+	}()
+
+	// the sender waits for the reader instead of failing
+	time.Sleep(300 * time.Millisecond)
+	assert.Less(t, sent.Load(), int32(total))
+	select {
+	case err := <-errs:
+		t.Fatalf("send returned while the queue was full: %v", err)
+	default:
+	}
+
+	for i := 0; i < total; i++ {
+		assert.Equal(t, "big", readMessage(t, conn).Type)
+	}
+	assert.NoError(t, <-errs)
+}
+
+func TestSlowConsumerBlockTimeout(t *testing.T) {
+	s := newServer(t, WithSendQueue(SendQueueConfig{Size: 1, WriteTimeout: 200 * time.Millisecond, Policy: PolicyBlock}))
+	conn := dial(t, newTestServer(t, s))
+	id := connID(t, conn)
+
+	// nobody reads, the blocked send gives up after WriteTimeout
+	err := fillQueue(t, s, id)
+	assert.True(t, errors.Is(err, ErrSendQueueFull) || errors.Is(err, ErrConnClosed), err)
+}
+
+func TestConcurrentBroadcastAndWrite(t *testing.T) {
+	s := newServer(t)
+	s.RegisterHandler("echo", func(w IWriter, data json.RawMessage, _ string) error {
+		for i := 0; i < 5; i++ {
+			if err := w.WriteJSON(ToMessage{Type: "echo", Data: data}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	url := newTestServer(t, s)
+
+	const (
+		clients    = 4
+		broadcasts = 48
+		echoes     = 10
+	)
+	conns := make([]*websocket.Conn, clients)
+	for i := range conns {
+		conns[i] = dial(t, url)
+		connID(t, conns[i])
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < broadcasts/4; j++ {
+				s.Broadcast(ToMessage{Type: "broadcast"})
+			}
+		}()
+	}
+	for _, conn := range conns {
+		require.NoError(t, conn.SetWriteDeadline(time.Now().Add(2*time.Second)))
+		for j := 0; j < echoes; j++ {
+			require.NoError(t, conn.WriteJSON(ToMessage{Type: "echo", Data: j}))
+		}
+	}
+	wg.Wait()
+
+	// every frame arrives intact, nothing is lost or interleaved
+	for _, conn := range conns {
+		counts := map[string]int{}
+		for i := 0; i < broadcasts+echoes*5; i++ {
+			counts[readMessage(t, conn).Type]++
+		}
+		assert.Equal(t, map[string]int{"broadcast": broadcasts, "echo": echoes * 5}, counts)
+	}
+}
@@ -0,0 +1,104 @@
+package ws
+
+// rooms 记录房间和连接的对应关系，连接断开时自动退出所有房间
+type rooms struct {
+	// room -> connID 集合
+	members map[string]map[string]struct{}
+	// connID -> room 集合
+	joined map[string]map[string]struct{}
+}
+
+func newRooms() *rooms {
+	return &rooms{
+		members: make(map[string]map[string]struct{}),
+		joined:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (r *rooms) join(connID, room string) {
+	if r.members[room] == nil {
+		r.members[room] = make(map[string]struct{})
+	}
+	r.members[room][connID] = struct{}{}
+	if r.joined[connID] == nil {
+		r.joined[connID] = make(map[string]struct{})
+	}
+	r.joined[connID][room] = struct{}{}
+}
+
+func (r *rooms) leave(connID, room string) {
+	delete(r.members[room], connID)
+	if len(r.members[room]) == 0 {
+		delete(r.members, room)
+	}
+	delete(r.joined[connID], room)
+	if len(r.joined[connID]) == 0 {
+		delete(r.joined, connID)
+	}
+}
+
+func (r *rooms) leaveAll(connID string) {
+	for room := range r.joined[connID] {
+		r.leave(connID, room)
+	}
+}
+
+// Join 把连接加入房间，连接不存在时返回 ErrConnNotFound
+func (h *Server) Join(connID, room string) error {
+	// 在 roomMu 内检查连接是否存在，连接断开时也在 roomMu 内删除连接，断开后不会再加入房间
+	h.roomMu.Lock()
+	defer h.roomMu.Unlock()
+	if _, ok := h.connections.Load(connID); !ok {
+		return ErrConnNotFound
+	}
+	h.rooms.join(connID, room)
+	return nil
+}
+
+// Leave 把连接移出房间
+func (h *Server) Leave(connID, room string) {
+	h.roomMu.Lock()
+	defer h.roomMu.Unlock()
+	h.rooms.leave(connID, room)
+}
+
+// Rooms 返回连接加入的所有房间
+func (h *Server) Rooms(connID string) []string {
+	h.roomMu.RLock()
+	defer h.roomMu.RUnlock()
+	res := make([]string, 0, len(h.rooms.joined[connID]))
+	for room := range h.rooms.joined[connID] {
+		res = append(res, room)
+	}
+	return res
+}
+
+// PublishTo 向房间内的所有连接发送消息，设置了 broker 时同时发送给其他节点上加入该房间的连接
+func (h *Server) PublishTo(room string, message any) {
+	h.publishLocal(room, message)
+	h.publishRemote(room, message)
+}
+
+func (h *Server) publishLocal(room string, message any) {
+	h.roomMu.RLock()
+	connIDs := make([]string, 0, len(h.rooms.members[room]))
+	for connID := range h.rooms.members[room] {
+		connIDs = append(connIDs, connID)
+	}
+	h.roomMu.RUnlock()
+
+	for _, connID := range connIDs {
+		if err := h.SendTo(connID, message); err != nil {
+			h.log.WithError(err).WithField("room", room).Error("Publish failed")
+		}
+	}
+}
+
+// SendTo 向指定连接发送消息，连接不存在时返回 ErrConnNotFound
+func (h *Server) SendTo(connID string, message any) error {
+	value, ok := h.connections.Load(connID)
+	if !ok {
+		return ErrConnNotFound
+	}
+	return value.(*outConn).WriteJSON(message)
+}
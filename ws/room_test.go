@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRoomServer(t *testing.T) *Server {
+	s := newServer(t)
+	s.RegisterHandler("join", func(w IWriter, data json.RawMessage, connID string) error {
+		var room string
+		if err := json.Unmarshal(data, &room); err != nil {
+			return err
+		}
+		return w.Join(connID, room)
+	})
+	return s
+}
+
+func TestRoomJoinLeavePublish(t *testing.T) {
+	s := newRoomServer(t)
+	url := newTestServer(t, s)
+	member := dial(t, url)
+	other := dial(t, url)
+	memberID := connID(t, member)
+
+	assert.Equal(t, 0, call(t, member, "join", "1", "room-1").Code)
+	assert.Equal(t, []string{"room-1"}, s.Rooms(memberID))
+
+	s.PublishTo("room-1", ToMessage{Type: "news", Data: "hello"})
+	msg := readMessage(t, member)
+	assert.Equal(t, "news", msg.Type)
+	assert.JSONEq(t, `"hello"`, string(msg.Data))
+
+	// the connection outside the room gets the pong, not the room message
+	assert.NoError(t, other.WriteJSON(ToMessage{Type: MessageTypePing}))
+	assert.Equal(t, MessageTypePong, readMessage(t, other).Type)
+
+	s.Leave(memberID, "room-1")
+	assert.Empty(t, s.Rooms(memberID))
+	s.PublishTo("room-1", ToMessage{Type: "news"})
+	assert.NoError(t, member.WriteJSON(ToMessage{Type: MessageTypePing}))
+	assert.Equal(t, MessageTypePong, readMessage(t, member).Type)
+}
+
+func TestRoomJoinUnknownConn(t *testing.T) {
+	s := newServer(t)
+	assert.ErrorIs(t, s.Join("missing", "room-1"), ErrConnNotFound)
+	assert.Empty(t, s.rooms.members)
+}
+
+func TestRoomDisconnectCleanup(t *testing.T) {
+	s := newRoomServer(t)
+	url := newTestServer(t, s)
+	conn := dial(t, url)
+	id := connID(t, conn)
+	call(t, conn, "join", "1", "room-1")
+	call(t, conn, "join", "2", "room-2")
+	assert.Len(t, s.Rooms(id), 2)
+
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		s.roomMu.RLock()
+		defer s.roomMu.RUnlock()
+		return len(s.rooms.members) == 0 && len(s.rooms.joined) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, s.Join(id, "room-1"), ErrConnNotFound)
+}
+
+func TestRoomJoinRacesDisconnect(t *testing.T) {
+	s := newServer(t)
+	url := newTestServer(t, s)
+	for i := 0; i < 20; i++ {
+		conn := dial(t, url)
+		id := connID(t, conn)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// keep joining until the disconnect cleanup has run
+			for {
+				if err := s.Join(id, fmt.Sprintf("room-%d", i)); errors.Is(err, ErrConnNotFound) {
+					return
+				}
+			}
+		}()
+		conn.Close()
+		<-done
+	}
+
+	// no membership survives its connection
+	s.roomMu.RLock()
+	defer s.roomMu.RUnlock()
+	assert.Empty(t, s.rooms.members)
+	assert.Empty(t, s.rooms.joined)
+}
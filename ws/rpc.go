@@ -0,0 +1,149 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// RPC 错误码，Code 为 0 表示成功
+const (
+	CodeUnknownMessageType = 404
+	CodeInternalError      = 500
+)
+
+// RPCError 是请求失败时的错误，对应消息中的 Code 和 Message
+// 服务端处理器返回 RPCError 时原样回复，返回其他错误时回复 CodeInternalError
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func NewRPCError(code int, message string) *RPCError {
+	return &RPCError{Code: code, Message: message}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error, code: %d, message: %s", e.Code, e.Message)
+}
+
+// Call 发送一个请求并等待服务端的回复，返回回复中的 Data
+// 服务端回复非 0 的 Code 时返回 *RPCError，ctx 结束时返回 ctx.Err()，连接断开时返回 ErrConnClosed
+func (c *Client) Call(ctx context.Context, msgType string, payload any) (json.RawMessage, error) {
+	id := strconv.FormatUint(c.nextID.Add(1), 10)
+	ch := make(chan FromMessage, 1)
+	c.pendingMu.Lock()
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.SendMessage(ToMessage{Type: msgType, ID: id, Data: payload}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrConnClosed
+		}
+		if resp.Code != 0 {
+			return nil, NewRPCError(resp.Code, resp.Message)
+		}
+		return resp.Data, nil
+	}
+}
+
+// resolvePending 把回复交给等待中的 Call，不是回复时返回 false
+func (c *Client) resolvePending(msg FromMessage) bool {
+	if msg.ID == "" {
+		return false
+	}
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	ch, ok := c.pending[msg.ID]
+	if !ok {
+		return false
+	}
+	delete(c.pending, msg.ID)
+	ch <- msg
+	return true
+}
+
+// failPending 连接断开时结束所有等待中的 Call
+func (c *Client) failPending() {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// Reply 回复当前请求，只能回复一次，消息没有 ID 时返回 ErrNotRequest
+func (w *writer) Reply(data any) error {
+	if err := w.claimReply(); err != nil {
+		return err
+	}
+	return w.conn.WriteJSON(ToMessage{Type: w.reqType, ID: w.reqID, Data: data})
+}
+
+// ReplyError 以错误回复当前请求，RPCError 原样回复，其他错误回复 CodeInternalError
+func (w *writer) ReplyError(err error) error {
+	if e := w.claimReply(); e != nil {
+		return e
+	}
+	return w.conn.WriteJSON(w.errorResponse(err))
+}
+
+// Defer 表示处理器返回后不自动回复空结果，由处理器启动的协程调用 Reply 或 ReplyError
+func (w *writer) Defer() {
+	w.deferred.Store(true)
+}
+
+// claimReply 保证每个请求只回复一次，处理器的协程和 finishRequest 可能同时回复
+func (w *writer) claimReply() error {
+	if w.reqID == "" {
+		return ErrNotRequest
+	}
+	if !w.replied.CompareAndSwap(false, true) {
+		return ErrAlreadyReplied
+	}
+	return nil
+}
+
+func (w *writer) errorResponse(err error) ToMessage {
+	resp := ToMessage{Type: w.reqType, ID: w.reqID}
+	if err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			resp.Code, resp.Message = rpcErr.Code, rpcErr.Message
+		} else {
+			resp.Code, resp.Message = CodeInternalError, err.Error()
+		}
+	}
+	return resp
+}
+
+// finishRequest 处理器返回后回复请求，处理器没有调用 Reply 时回复空结果，返回错误时回复错误码
+// 调用过 Defer 且没有返回错误时不回复
+func (w *writer) finishRequest(err error) error {
+	if w.reqID == "" || (err == nil && w.deferred.Load()) {
+		return err
+	}
+	if w.claimReply() != nil {
+		return err
+	}
+	resp := w.errorResponse(err)
+	if werr := w.conn.WriteJSON(resp); werr != nil {
+		return errors.Join(err, werr)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRPCServer(t *testing.T) *Server {
+	s := newServer(t)
+	s.RegisterHandler("add", func(w IWriter, data json.RawMessage, _ string) error {
+		var args [2]int
+		if err := json.Unmarshal(data, &args); err != nil {
+			return err
+		}
+		return w.Reply(args[0] + args[1])
+	})
+	s.RegisterHandler("denied", func(w IWriter, _ json.RawMessage, _ string) error {
+		return NewRPCError(403, "no permission")
+	})
+	s.RegisterHandler("broken", func(w IWriter, _ json.RawMessage, _ string) error {
+		return errors.New("database is down")
+	})
+	s.RegisterHandler("slow", func(w IWriter, _ json.RawMessage, _ string) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	s.RegisterHandler("async", func(w IWriter, data json.RawMessage, _ string) error {
+		w.Defer()
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			if string(data) == `"fail"` {
+				w.ReplyError(NewRPCError(409, "conflict"))
+				return
+			}
+			w.Reply("done")
+		}()
+		return nil
+	})
+	return s
+}
+
+func TestCallSuccess(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	data, err := c.Call(context.Background(), "add", [2]int{1, 2})
+	require.NoError(t, err)
+	assert.JSONEq(t, "3", string(data))
+}
+
+func TestCallRPCError(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	_, err := c.Call(context.Background(), "denied", nil)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, &RPCError{Code: 403, Message: "no permission"}, rpcErr)
+}
+
+func TestCallInternalError(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	_, err := c.Call(context.Background(), "broken", nil)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeInternalError, rpcErr.Code)
+	assert.Equal(t, "database is down", rpcErr.Message)
+}
+
+func TestCallUnknownType(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	_, err := c.Call(context.Background(), "missing", nil)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeUnknownMessageType, rpcErr.Code)
+}
+
+func TestCallTimeout(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Call(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the late reply is dropped and the connection keeps working
+	data, err := c.Call(context.Background(), "add", [2]int{2, 3})
+	require.NoError(t, err)
+	assert.JSONEq(t, "5", string(data))
+	c.pendingMu.Lock()
+	assert.Empty(t, c.pending)
+	c.pendingMu.Unlock()
+}
+
+func TestCallConnClosed(t *testing.T) {
+	s := newRPCServer(t)
+	c := newClient(t, newTestServer(t, s))
+	id := clientConnID(t, c)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.Call(context.Background(), "slow", nil)
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool {
+		c.pendingMu.Lock()
+		defer c.pendingMu.Unlock()
+		return len(c.pending) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// the connection drops before the reply is sent
+	value, ok := s.connections.Load(id)
+	require.True(t, ok)
+	value.(*outConn).close()
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrConnClosed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Call did not return after the connection closed")
+	}
+}
+
+func TestCallDeferredReply(t *testing.T) {
+	c := newClient(t, newTestServer(t, newRPCServer(t)))
+	// the handler returned long before, the reply comes from its goroutine
+	data, err := c.Call(context.Background(), "async", nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `"done"`, string(data))
+
+	_, err = c.Call(context.Background(), "async", "fail")
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, &RPCError{Code: 409, Message: "conflict"}, rpcErr)
+}
+
+func TestReplyOnce(t *testing.T) {
+	s := newServer(t)
+	results := make(chan error, 2)
+	s.RegisterHandler("twice", func(w IWriter, _ json.RawMessage, _ string) error {
+		// without Defer a goroutine races the automatic reply, exactly one of them wins
+		go func() { results <- w.Reply("goroutine") }()
+		results <- w.Reply("handler")
+		return nil
+	})
+	conn := dial(t, newTestServer(t, s))
+	call(t, conn, "twice", "1", nil)
+
+	errs := []error{<-results, <-results}
+	assert.Contains(t, errs, nil)
+	assert.Contains(t, errs, ErrAlreadyReplied)
+	// no second reply for the same request follows
+	assert.NoError(t, conn.WriteJSON(ToMessage{Type: MessageTypePing}))
+	assert.Equal(t, MessageTypePong, readMessage(t, conn).Type)
+}
@@ -1,12 +1,14 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,7 +26,19 @@ type Server struct {
 	// errorHandler func(conn *websocket.Conn, code common.AIAPPErrCode, err error, conversationID string)
 	// 连接状态管理
 	connState sync.Map
-	log       *logrus.Entry
+	// 房间
+	roomMu sync.RWMutex
+	rooms  *rooms
+	// 多节点消息分发
+	broker IBroker
+	nodeID string
+	// 每个连接的出站队列配置
+	sendQueue SendQueueConfig
+	// 认证和按用户查找连接
+	authenticate func(r *http.Request) (principal any, err error)
+	userMu       sync.RWMutex
+	users        *rooms
+	log          *logrus.Entry
 }
 
 // NewServer 创建一个新的 WebSocket 处理器
@@ -35,7 +49,10 @@ func NewServer(ops ...ServerOptions) (*Server, error) {
 				return true // 允许所有来源，生产环境中应该更严格
 			},
 		},
-		handler: make(map[string]HandlerFunc),
+		handler:   make(map[string]HandlerFunc),
+		rooms:     newRooms(),
+		users:     newRooms(),
+		sendQueue: defaultSendQueueConfig,
 	}
 	for _, opt := range ops {
 		if err := opt(s); err != nil {
@@ -45,6 +62,12 @@ func NewServer(ops ...ServerOptions) (*Server, error) {
 	if s.log == nil {
 		s.log = logrus.WithField("module", "ws")
 	}
+	if s.nodeID == "" {
+		s.nodeID = newNodeID()
+	}
+	if err := s.startBroker(); err != nil {
+		return nil, err
+	}
 	if _, exists := s.handler[MessageTypePing]; !exists {
 		s.RegisterHandler(MessageTypePing, s.handlPingMessage)
 	}
@@ -60,10 +83,15 @@ func (h *Server) RegisterHandler(msgType string, handler HandlerFunc) {
 	h.handler[msgType] = handler
 }
 
-// Broadcast 向所有连接广播消息
+// Broadcast 向所有连接广播消息，设置了 broker 时同时发送给其他节点的连接
 func (h *Server) Broadcast(message any) {
+	h.broadcastLocal(message)
+	h.publishRemote("", message)
+}
+
+func (h *Server) broadcastLocal(message any) {
 	h.connections.Range(func(key, value any) bool {
-		conn := value.(*websocket.Conn)
+		conn := value.(*outConn)
 		if err := conn.WriteJSON(message); err != nil {
 			h.log.WithError(err).Error("Broadcast failed")
 		}
@@ -77,6 +105,17 @@ func (h *Server) GinHandler() gin.HandlerFunc {
 
 // HandleConnection 处理 WebSocket 连接
 func (h *Server) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	var principal any
+	if h.authenticate != nil {
+		p, err := h.authenticate(r)
+		if err != nil {
+			h.log.WithError(err).Warn("Authenticate failed")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		principal = p
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.log.WithError(err).Error("Failed to upgrade connection")
@@ -84,16 +123,37 @@ func (h *Server) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	connID := h.generateConnID()
-	h.connections.Store(connID, conn)
+	ctx := context.WithValue(r.Context(), connInfoKey{}, &ConnInfo{
+		ID:          connID,
+		Principal:   principal,
+		RemoteAddr:  r.RemoteAddr,
+		Header:      r.Header,
+		URL:         r.URL,
+		ConnectedAt: time.Now(),
+	})
+	out := newOutConn(conn, h.sendQueue)
+	h.connections.Store(connID, out)
 	h.connState.Store(connID, true) // Mark connection as active
+	userID := userIDOf(principal)
+	if userID != "" {
+		h.userMu.Lock()
+		h.users.join(connID, userID)
+		h.userMu.Unlock()
+	}
 
 	// Create a done channel for cleanup coordination
 	done := make(chan struct{})
 	defer func() {
 		close(done)
-		conn.Close()
+		out.close()
+		h.roomMu.Lock()
 		h.connections.Delete(connID)
+		h.rooms.leaveAll(connID)
+		h.roomMu.Unlock()
 		h.connState.Delete(connID)
+		h.userMu.Lock()
+		h.users.leaveAll(connID)
+		h.userMu.Unlock()
 	}()
 
 	for {
@@ -126,7 +186,7 @@ func (h *Server) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 处理消息
-		if err := h.handleMessage(conn, message, connID); err != nil {
+		if err := h.handleMessage(ctx, out, message, connID); err != nil {
 			h.log.WithError(err).Error("Message handling failed")
 			continue
 		}
@@ -134,7 +194,7 @@ func (h *Server) HandleConnection(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleMessage 处理接收到的消息
-func (h *Server) handleMessage(conn *websocket.Conn, message []byte, connID string) error {
+func (h *Server) handleMessage(ctx context.Context, conn *outConn, message []byte, connID string) error {
 	// 尝试解析为命令消息
 	var typeMsg FromMessage
 	err := json.Unmarshal(message, &typeMsg)
@@ -142,11 +202,16 @@ func (h *Server) handleMessage(conn *websocket.Conn, message []byte, connID stri
 		return fmt.Errorf("failed to parse message: %w, data: %s", err, string(message))
 	}
 
+	w := h.getWriter(ctx, conn, typeMsg)
 	if handler, exists := h.handler[typeMsg.Type]; exists {
-		return handler(h.getWriter(conn), typeMsg.Data, connID)
+		return w.finishRequest(handler(w, typeMsg.Data, connID))
 	}
 
-	return fmt.Errorf("unknown message type: %s, %w", typeMsg.Type, ErrUnknownMessageType)
+	err = fmt.Errorf("unknown message type: %s, %w", typeMsg.Type, ErrUnknownMessageType)
+	if typeMsg.ID != "" {
+		w.finishRequest(NewRPCError(CodeUnknownMessageType, err.Error()))
+	}
+	return err
 }
 
 func (h *Server) handlPingMessage(writer IWriter, message json.RawMessage, connID string) error {
@@ -155,10 +220,13 @@ func (h *Server) handlPingMessage(writer IWriter, message json.RawMessage, connI
 	})
 }
 
-func (h *Server) getWriter(conn *websocket.Conn) IWriter {
+func (h *Server) getWriter(ctx context.Context, conn *outConn, msg FromMessage) *writer {
 	return &writer{
-		c:    h,
-		conn: conn,
+		ctx:     ctx,
+		c:       h,
+		conn:    conn,
+		reqType: msg.Type,
+		reqID:   msg.ID,
 	}
 }
 
@@ -168,8 +236,18 @@ func (h *Server) generateConnID() string {
 }
 
 type writer struct {
-	conn *websocket.Conn
+	ctx  context.Context
+	conn *outConn
 	c    *Server
+	// 当前请求，reqID 为空表示不需要回复
+	reqType  string
+	reqID    string
+	replied  atomic.Bool
+	deferred atomic.Bool
+}
+
+func (w *writer) Context() context.Context {
+	return w.ctx
 }
 
 func (w *writer) WriteJSON(message any) error {
@@ -179,3 +257,19 @@ func (w *writer) WriteJSON(message any) error {
 func (w *writer) Broadcast(message any) {
 	w.c.Broadcast(message)
 }
+
+func (w *writer) Join(connID, room string) error {
+	return w.c.Join(connID, room)
+}
+
+func (w *writer) Leave(connID, room string) {
+	w.c.Leave(connID, room)
+}
+
+func (w *writer) PublishTo(room string, message any) {
+	w.c.PublishTo(room, message)
+}
+
+func (w *writer) SendTo(connID string, message any) error {
+	return w.c.SendTo(connID, message)
+}
@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// connections closing is the normal path in these tests, keep the output readable
+	logrus.SetLevel(logrus.WarnLevel)
+	os.Exit(m.Run())
+}
+
+// newTestServer starts s behind an httptest server and returns its ws:// url
+func newTestServer(t *testing.T, s *Server) string {
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleConnection))
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) FromMessage {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg FromMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+// call sends a request and waits for its reply, messages before the reply are skipped
+func call(t *testing.T, conn *websocket.Conn, msgType, id string, data any) FromMessage {
+	require.NoError(t, conn.WriteJSON(ToMessage{Type: msgType, ID: id, Data: data}))
+	for {
+		msg := readMessage(t, conn)
+		if msg.ID == id {
+			return msg
+		}
+	}
+}
+
+// connID asks the server for the id of conn
+func connID(t *testing.T, conn *websocket.Conn) string {
+	var id string
+	require.NoError(t, json.Unmarshal(call(t, conn, "whoami", "whoami", nil).Data, &id))
+	return id
+}
+
+func newServer(t *testing.T, ops ...ServerOptions) *Server {
+	s, err := NewServer(ops...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	s.RegisterHandler("whoami", func(w IWriter, _ json.RawMessage, connID string) error {
+		return w.Reply(connID)
+	})
+	return s
+}
+
+func TestPing(t *testing.T) {
+	url := newTestServer(t, newServer(t))
+	conn := dial(t, url)
+	require.NoError(t, conn.WriteJSON(ToMessage{Type: MessageTypePing}))
+	assert.Equal(t, MessageTypePong, readMessage(t, conn).Type)
+}
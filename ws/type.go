@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -14,6 +15,9 @@ const (
 var (
 	ErrUnknownMessageType   = fmt.Errorf("unknown message type")
 	ErrInvalidMessageFormat = fmt.Errorf("invalid message format")
+	ErrConnNotFound         = fmt.Errorf("connection not found")
+	ErrNotRequest           = fmt.Errorf("message is not a request")
+	ErrAlreadyReplied       = fmt.Errorf("request already replied")
 )
 
 type ClientOptions = func(*Client) error
@@ -21,7 +25,9 @@ type ServerOptions = func(*Server) error
 
 // FromMessage 命令消息
 type FromMessage struct {
-	Type      string          `json:"type"`
+	Type string `json:"type"`
+	// ID 是请求 ID，回复时原样带回
+	ID        string          `json:"id,omitempty"`
 	Data      json.RawMessage `json:"data,omitempty"`
 	Timestamp int64           `json:"timestamp,omitempty"`
 	Code      int             `json:"code,omitempty"`
@@ -31,6 +37,7 @@ type FromMessage struct {
 // ToMessage 响应消息
 type ToMessage struct {
 	Type      string `json:"type"`
+	ID        string `json:"id,omitempty"`
 	Data      any    `json:"data,omitempty"`
 	Timestamp int64  `json:"timestamp,omitempty"`
 	Code      int    `json:"code,omitempty"`
@@ -38,8 +45,27 @@ type ToMessage struct {
 }
 
 type IWriter interface {
+	// Context 是连接的上下文，可以通过 ConnInfoFrom 和 PrincipalFrom 获取连接的元数据
+	// 处理器在读协程中同步执行，连接断开并清理完成后上下文才会取消，
+	// 处理器内部观察不到取消，只有处理器启动的协程可以用它感知连接断开
+	Context() context.Context
 	WriteJSON(message any) error
 	Broadcast(message any)
+	// Join 把连接加入房间，连接断开时自动退出
+	Join(connID, room string) error
+	// Leave 把连接移出房间
+	Leave(connID, room string)
+	// PublishTo 向房间内的所有连接发送消息
+	PublishTo(room string, message any)
+	// SendTo 向指定连接发送消息
+	SendTo(connID string, message any) error
+	// Reply 回复当前请求，处理器返回的错误会自动回复为 Code 和 Message
+	// 处理器返回时还没有回复的请求会自动回复空结果，需要在协程中回复时先调用 Defer
+	Reply(data any) error
+	// ReplyError 以错误回复当前请求，用于 Defer 之后在协程中回复失败
+	ReplyError(err error) error
+	// Defer 表示处理器返回后不自动回复，之后必须调用 Reply 或 ReplyError，处理器返回错误时仍然回复错误
+	Defer()
 }
 
 type IClientWriter interface {